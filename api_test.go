@@ -21,30 +21,35 @@
 package stack_test
 
 import (
+	"context"
+	"iter"
+	"slices"
+	"sync"
 	"testing"
+	"time"
 
-	"github.com/ef-ds/stack"
+	"github.com/ef-ds/stack/v2"
 )
 
 func TestPopWithZeroValueShouldReturnReadyToUsestack(t *testing.T) {
-	var s stack.Stack
+	var s stack.Stack[int]
 	s.Push(1)
 	s.Push(2)
 
 	v, ok := s.Back()
-	if !ok || v.(int) != 2 {
+	if !ok || v != 2 {
 		t.Errorf("Expected: 2; Got: %d", v)
 	}
 	v, ok = s.Pop()
-	if !ok || v.(int) != 2 {
+	if !ok || v != 2 {
 		t.Errorf("Expected: 2; Got: %d", v)
 	}
 	v, ok = s.Back()
-	if !ok || v.(int) != 1 {
+	if !ok || v != 1 {
 		t.Errorf("Expected: 1; Got: %d", v)
 	}
 	v, ok = s.Pop()
-	if !ok || v.(int) != 1 {
+	if !ok || v != 1 {
 		t.Errorf("Expected: 1; Got: %d", v)
 	}
 	_, ok = s.Back()
@@ -58,7 +63,7 @@ func TestPopWithZeroValueShouldReturnReadyToUsestack(t *testing.T) {
 }
 
 func TestWithZeroValueAndEmptyShouldReturnAsEmpty(t *testing.T) {
-	var s stack.Stack
+	var s stack.Stack[int]
 
 	if _, ok := s.Back(); ok {
 		t.Error("Expected: false as the queue is empty; Got: true")
@@ -75,7 +80,7 @@ func TestWithZeroValueAndEmptyShouldReturnAsEmpty(t *testing.T) {
 }
 
 func TestInitShouldReturnEmptystack(t *testing.T) {
-	var s stack.Stack
+	var s stack.Stack[int]
 	s.Push(1)
 
 	s.Init()
@@ -92,7 +97,7 @@ func TestInitShouldReturnEmptystack(t *testing.T) {
 }
 
 func TestPopWithNilValuesShouldReturnAllValuesInOrder(t *testing.T) {
-	s := stack.New()
+	s := stack.New[interface{}]()
 	s.Push(1)
 	s.Push(nil)
 	s.Push(2)
@@ -119,3 +124,1919 @@ func TestPopWithNilValuesShouldReturnAllValuesInOrder(t *testing.T) {
 		t.Error("Expected: empty slice (ok=false); Got: ok=true")
 	}
 }
+
+func TestPushPopStructValuesShouldNotAllocatePerValue(t *testing.T) {
+	type item struct {
+		id    int
+		score float64
+	}
+	var s stack.Stack[item]
+	s.Push(item{id: 0})
+
+	allocs := testing.AllocsPerRun(100, func() {
+		s.Push(item{id: 1, score: 1.5})
+		if v, ok := s.Pop(); !ok || v.id != 1 || v.score != 1.5 {
+			t.Errorf("Expected: {1 1.5}; Got: %v", v)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Expected: 0 allocations; Got: %v", allocs)
+	}
+}
+
+func TestPopWithEmptyStackShouldReturnZeroValue(t *testing.T) {
+	var s stack.Stack[string]
+	s.Push("a")
+	s.Pop()
+
+	if v, ok := s.Pop(); ok || v != "" {
+		t.Errorf("Expected: empty string and false; Got: %q and %t", v, ok)
+	}
+	if v, ok := s.Back(); ok || v != "" {
+		t.Errorf("Expected: empty string and false; Got: %q and %t", v, ok)
+	}
+}
+
+func TestAllShouldIterateFromTopToBottomWithoutRemovingValues(t *testing.T) {
+	var s stack.Stack[int]
+	for i := 0; i < pushCount; i++ {
+		s.Push(i)
+	}
+
+	count := 0
+	for i, v := range s.All() {
+		if i != count || v != pushCount-1-count {
+			t.Errorf("Expected: %d, %d; Got: %d, %d", count, pushCount-1-count, i, v)
+		}
+		count++
+	}
+	if count != pushCount {
+		t.Errorf("Expected: %d values; Got: %d", pushCount, count)
+	}
+	if s.Len() != pushCount {
+		t.Errorf("Expected: %d; Got: %d", pushCount, s.Len())
+	}
+}
+
+func TestBackwardShouldIterateFromBottomToTopWithoutRemovingValues(t *testing.T) {
+	var s stack.Stack[int]
+	for i := 0; i < pushCount; i++ {
+		s.Push(i)
+	}
+
+	count := 0
+	for i, v := range s.Backward() {
+		if i != pushCount-1-count || v != count {
+			t.Errorf("Expected: %d, %d; Got: %d, %d", pushCount-1-count, count, i, v)
+		}
+		count++
+	}
+	if count != pushCount {
+		t.Errorf("Expected: %d values; Got: %d", pushCount, count)
+	}
+	if s.Len() != pushCount {
+		t.Errorf("Expected: %d; Got: %d", pushCount, s.Len())
+	}
+}
+
+func TestAllAndBackwardWithEmptyStackShouldNotYield(t *testing.T) {
+	var s stack.Stack[int]
+	s.Push(1)
+	s.Pop()
+
+	for range s.All() {
+		t.Error("Expected: no values; Got: value")
+	}
+	for range s.Backward() {
+		t.Error("Expected: no values; Got: value")
+	}
+}
+
+func TestAllAndBackwardShouldStopOnBreak(t *testing.T) {
+	var s stack.Stack[int]
+	for i := 0; i < pushCount; i++ {
+		s.Push(i)
+	}
+
+	for _, v := range s.All() {
+		if v != pushCount-1 {
+			t.Errorf("Expected: %d; Got: %d", pushCount-1, v)
+		}
+		break
+	}
+	for _, v := range s.Backward() {
+		if v != 0 {
+			t.Errorf("Expected: 0; Got: %d", v)
+		}
+		break
+	}
+}
+
+func TestAllAndBackwardShouldPanicWhenStackIsModified(t *testing.T) {
+	tests := map[string]func(s *stack.Stack[int]){
+		"All/Push": func(s *stack.Stack[int]) {
+			for range s.All() {
+				s.Push(0)
+			}
+		},
+		"All/Pop": func(s *stack.Stack[int]) {
+			for range s.All() {
+				s.Pop()
+			}
+		},
+		"All/Set": func(s *stack.Stack[int]) {
+			for i, v := range s.All() {
+				s.Set(s.Len()-1-i, v+1)
+			}
+		},
+		"Backward/Push": func(s *stack.Stack[int]) {
+			for range s.Backward() {
+				s.Push(0)
+			}
+		},
+		"Backward/Set": func(s *stack.Stack[int]) {
+			for i, v := range s.Backward() {
+				s.Set(s.Len()-1-i, v+1)
+			}
+		},
+		"Backward/Init": func(s *stack.Stack[int]) {
+			for range s.Backward() {
+				s.Init()
+			}
+		},
+	}
+	for name, modify := range tests {
+		t.Run(name, func(t *testing.T) {
+			var s stack.Stack[int]
+			s.Push(1)
+			s.Push(2)
+
+			defer func() {
+				if r := recover(); r == nil {
+					t.Error("Expected: panic; Got: no panic")
+				}
+			}()
+			modify(&s)
+		})
+	}
+}
+
+func TestDrainShouldPopAllValuesInOrder(t *testing.T) {
+	var s stack.Stack[int]
+	for i := 0; i < pushCount; i++ {
+		s.Push(i)
+	}
+
+	count := pushCount - 1
+	for v := range s.Drain() {
+		if v != count {
+			t.Errorf("Expected: %d; Got: %d", count, v)
+		}
+		if s.Len() != count {
+			t.Errorf("Expected: %d; Got: %d", count, s.Len())
+		}
+		count--
+	}
+	if count != -1 {
+		t.Errorf("Expected: %d values; Got: %d", pushCount, pushCount-1-count)
+	}
+	if _, ok := s.Pop(); ok {
+		t.Error("Expected: false as the stack is empty; Got: true")
+	}
+}
+
+func TestDrainShouldLeaveRemainingValuesOnBreak(t *testing.T) {
+	var s stack.Stack[int]
+	for i := 0; i < 10; i++ {
+		s.Push(i)
+	}
+
+	for v := range s.Drain() {
+		if v == 7 {
+			break
+		}
+	}
+	if s.Len() != 7 {
+		t.Errorf("Expected: 7; Got: %d", s.Len())
+	}
+	if v, ok := s.Back(); !ok || v != 6 {
+		t.Errorf("Expected: 6; Got: %d", v)
+	}
+}
+
+func TestDrainShouldPopValuesPushedWhileDraining(t *testing.T) {
+	var s stack.Stack[int]
+	s.Push(1)
+
+	var got []int
+	for v := range s.Drain() {
+		got = append(got, v)
+		if v == 1 {
+			s.Push(3)
+			s.Push(2)
+		}
+	}
+	if want := []int{1, 2, 3}; !slices.Equal(got, want) {
+		t.Errorf("Expected: %v; Got: %v", want, got)
+	}
+}
+
+func TestDrainShouldComposeWithIteratorTooling(t *testing.T) {
+	var s stack.Stack[int]
+	for i := 0; i < 5; i++ {
+		s.Push(i)
+	}
+
+	next, stop := iter.Pull(s.Drain())
+	if v, ok := next(); !ok || v != 4 {
+		t.Errorf("Expected: 4; Got: %d", v)
+	}
+	stop()
+	if s.Len() != 4 {
+		t.Errorf("Expected: 4; Got: %d", s.Len())
+	}
+
+	if got, want := slices.Collect(s.Drain()), []int{3, 2, 1, 0}; !slices.Equal(got, want) {
+		t.Errorf("Expected: %v; Got: %v", want, got)
+	}
+	if s.Len() != 0 {
+		t.Errorf("Expected: 0; Got: %d", s.Len())
+	}
+}
+
+func TestAtShouldReturnValuesFromTheBack(t *testing.T) {
+	var s stack.Stack[int]
+	for i := 0; i < pushCount; i++ {
+		s.Push(i)
+	}
+
+	for i := 0; i < pushCount; i++ {
+		if v, ok := s.At(i); !ok || v != pushCount-1-i {
+			t.Errorf("Expected: %d; Got: %d", pushCount-1-i, v)
+		}
+	}
+	if v, ok := s.Back(); !ok || v != pushCount-1 {
+		t.Errorf("Expected: %d; Got: %d", pushCount-1, v)
+	}
+	if s.Len() != pushCount {
+		t.Errorf("Expected: %d; Got: %d", pushCount, s.Len())
+	}
+}
+
+func TestAtWithOutOfRangeDepthShouldReturnFalse(t *testing.T) {
+	var s stack.Stack[int]
+	if _, ok := s.At(0); ok {
+		t.Error("Expected: false as the stack is empty; Got: true")
+	}
+
+	s.Push(1)
+	if v, ok := s.At(-1); ok || v != 0 {
+		t.Errorf("Expected: 0 and false; Got: %d and %t", v, ok)
+	}
+	if v, ok := s.At(1); ok || v != 0 {
+		t.Errorf("Expected: 0 and false; Got: %d and %t", v, ok)
+	}
+}
+
+func TestPeekNShouldCopyValuesFromTheBack(t *testing.T) {
+	var s stack.Stack[int]
+	for i := 0; i < pushCount; i++ {
+		s.Push(i)
+	}
+
+	dst := make([]int, pushCount+1)
+	if c := s.PeekN(dst, pushCount); c != pushCount {
+		t.Errorf("Expected: %d; Got: %d", pushCount, c)
+	}
+	for i := 0; i < pushCount; i++ {
+		if dst[i] != pushCount-1-i {
+			t.Errorf("Expected: %d; Got: %d", pushCount-1-i, dst[i])
+		}
+	}
+	if s.Len() != pushCount {
+		t.Errorf("Expected: %d; Got: %d", pushCount, s.Len())
+	}
+}
+
+func TestPeekNShouldCopyNoMoreThanAvailable(t *testing.T) {
+	var s stack.Stack[int]
+	for i := 0; i < 5; i++ {
+		s.Push(i)
+	}
+
+	dst := make([]int, 3)
+	if c := s.PeekN(dst, 10); c != 3 || !slices.Equal(dst, []int{4, 3, 2}) {
+		t.Errorf("Expected: 3, [4 3 2]; Got: %d, %v", c, dst)
+	}
+	dst = make([]int, 10)
+	if c := s.PeekN(dst, 10); c != 5 || !slices.Equal(dst[:c], []int{4, 3, 2, 1, 0}) {
+		t.Errorf("Expected: 5, [4 3 2 1 0]; Got: %d, %v", c, dst[:c])
+	}
+	if c := s.PeekN(dst, 2); c != 2 || !slices.Equal(dst[:c], []int{4, 3}) {
+		t.Errorf("Expected: 2, [4 3]; Got: %d, %v", c, dst[:c])
+	}
+	if c := s.PeekN(dst, -1); c != 0 {
+		t.Errorf("Expected: 0; Got: %d", c)
+	}
+	if c := s.PeekN(nil, 1); c != 0 {
+		t.Errorf("Expected: 0; Got: %d", c)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		s.PeekN(dst, 5)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected: 0 allocations; Got: %v", allocs)
+	}
+}
+
+func TestGetShouldReturnValuesFromTheBottom(t *testing.T) {
+	var s stack.Stack[int]
+	for i := 0; i < pushCount; i++ {
+		s.Push(i)
+	}
+
+	for i := 0; i < pushCount; i++ {
+		if v, ok := s.Get(i); !ok || v != i {
+			t.Errorf("Expected: %d; Got: %d", i, v)
+		}
+	}
+	if _, ok := s.Get(-1); ok {
+		t.Error("Expected: false as the index is out of range; Got: true")
+	}
+	if _, ok := s.Get(pushCount); ok {
+		t.Error("Expected: false as the index is out of range; Got: true")
+	}
+}
+
+func TestSetShouldReplaceValuesFromTheBottom(t *testing.T) {
+	var s stack.Stack[int]
+	for i := 0; i < pushCount; i++ {
+		s.Push(i)
+	}
+
+	for i := 0; i < pushCount; i++ {
+		if !s.Set(i, i*2) {
+			t.Errorf("Expected: true for index %d; Got: false", i)
+		}
+	}
+	if s.Set(-1, 0) || s.Set(pushCount, 0) {
+		t.Error("Expected: false as the index is out of range; Got: true")
+	}
+	if s.Len() != pushCount {
+		t.Errorf("Expected: %d; Got: %d", pushCount, s.Len())
+	}
+	for i := pushCount - 1; i >= 0; i-- {
+		if v, ok := s.Pop(); !ok || v != i*2 {
+			t.Errorf("Expected: %d; Got: %d", i*2, v)
+		}
+	}
+
+	if s.Set(0, 1) {
+		t.Error("Expected: false as the stack is empty; Got: true")
+	}
+}
+
+func TestPushAllShouldPushValuesInOrder(t *testing.T) {
+	for _, count := range []int{0, 1, 7, 8, 9, 511, 512, 513, pushCount} {
+		var s stack.Stack[int]
+		s.Push(-1)
+		vs := make([]int, count)
+		for i := range vs {
+			vs[i] = i
+		}
+
+		s.PushAll(vs...)
+		if s.Len() != count+1 {
+			t.Errorf("Expected: %d; Got: %d", count+1, s.Len())
+		}
+		for i := count - 1; i >= -1; i-- {
+			if v, ok := s.Pop(); !ok || v != i {
+				t.Errorf("Expected: %d; Got: %d", i, v)
+			}
+		}
+	}
+}
+
+func TestPushAllShouldMixWithPush(t *testing.T) {
+	var s stack.Stack[int]
+	want := 0
+	for i := 0; i < 100; i++ {
+		vs := make([]int, i*7)
+		for j := range vs {
+			vs[j] = want
+			want++
+		}
+		s.PushAll(vs...)
+		s.Push(want)
+		want++
+	}
+
+	for i := want - 1; i >= 0; i-- {
+		if v, ok := s.Pop(); !ok || v != i {
+			t.Fatalf("Expected: %d; Got: %d", i, v)
+		}
+	}
+	if s.Len() != 0 {
+		t.Errorf("Expected: 0; Got: %d", s.Len())
+	}
+}
+
+func TestPushSeqShouldPushValuesInOrder(t *testing.T) {
+	var s stack.Stack[int]
+	s.PushSeq(slices.Values([]int{1, 2, 3}))
+
+	if got, want := slices.Collect(s.Drain()), []int{3, 2, 1}; !slices.Equal(got, want) {
+		t.Errorf("Expected: %v; Got: %v", want, got)
+	}
+}
+
+func TestPopNShouldPopValuesInOrder(t *testing.T) {
+	var s stack.Stack[int]
+	for i := 0; i < pushCount; i++ {
+		s.Push(i)
+	}
+
+	dst := make([]int, 100)
+	want := pushCount - 1
+	for s.Len() > 0 {
+		c := s.PopN(dst, len(dst))
+		if c != min(len(dst), want+1) {
+			t.Errorf("Expected: %d; Got: %d", min(len(dst), want+1), c)
+		}
+		for _, v := range dst[:c] {
+			if v != want {
+				t.Errorf("Expected: %d; Got: %d", want, v)
+			}
+			want--
+		}
+		if s.Len() != want+1 {
+			t.Errorf("Expected: %d; Got: %d", want+1, s.Len())
+		}
+	}
+	if c := s.PopN(dst, len(dst)); c != 0 {
+		t.Errorf("Expected: 0; Got: %d", c)
+	}
+
+	s.Push(1)
+	s.Push(2)
+	if c := s.PopN(dst, -1); c != 0 {
+		t.Errorf("Expected: 0; Got: %d", c)
+	}
+	if c := s.PopN(dst[:1], 2); c != 1 || dst[0] != 2 {
+		t.Errorf("Expected: 1, 2; Got: %d, %d", c, dst[0])
+	}
+	if c := s.PopN(dst, 1); c != 1 || dst[0] != 1 {
+		t.Errorf("Expected: 1, 1; Got: %d, %d", c, dst[0])
+	}
+}
+
+func TestFromSliceShouldReturnStackWithValuesInOrder(t *testing.T) {
+	for _, count := range []int{0, 1, 8, 9, 512, 513, pushCount} {
+		vs := make([]int, count)
+		for i := range vs {
+			vs[i] = i
+		}
+
+		s := stack.FromSlice(vs)
+		if s.Len() != count {
+			t.Errorf("Expected: %d; Got: %d", count, s.Len())
+		}
+		if count > 0 {
+			vs[0] = -1 // The stack shouldn't share memory with vs.
+		}
+		for i := count - 1; i >= 0; i-- {
+			if v, ok := s.Pop(); !ok || v != i {
+				t.Errorf("Expected: %d; Got: %d", i, v)
+			}
+		}
+	}
+}
+
+func TestToSliceShouldReturnValuesInPushOrder(t *testing.T) {
+	var s stack.Stack[int]
+	if got := s.ToSlice(); got == nil || len(got) != 0 {
+		t.Errorf("Expected: empty slice; Got: %v", got)
+	}
+
+	want := make([]int, pushCount)
+	for i := range want {
+		want[i] = i
+		s.Push(i)
+	}
+	if got := s.ToSlice(); !slices.Equal(got, want) {
+		t.Errorf("Expected: %d values in push order; Got: %v", pushCount, got)
+	}
+	if s.Len() != pushCount {
+		t.Errorf("Expected: %d; Got: %d", pushCount, s.Len())
+	}
+}
+
+func TestAppendToShouldAppendValuesInPushOrder(t *testing.T) {
+	s := stack.FromSlice([]int{1, 2, 3})
+	dst := make([]int, 1, 4)
+
+	got := s.AppendTo(dst)
+	if want := []int{0, 1, 2, 3}; !slices.Equal(got, want) {
+		t.Errorf("Expected: %v; Got: %v", want, got)
+	}
+	if &got[0] != &dst[0] {
+		t.Error("Expected: values appended in place; Got: new slice")
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		s.AppendTo(dst[:0])
+	})
+	if allocs != 0 {
+		t.Errorf("Expected: 0 allocations; Got: %v", allocs)
+	}
+}
+
+func TestCloneShouldReturnIndependentCopy(t *testing.T) {
+	var s stack.Stack[int]
+	c := s.Clone()
+	if c.Len() != 0 {
+		t.Errorf("Expected: 0; Got: %d", c.Len())
+	}
+	c.Push(1)
+	if s.Len() != 0 {
+		t.Errorf("Expected: 0; Got: %d", s.Len())
+	}
+
+	for i := 0; i < pushCount; i++ {
+		s.Push(i)
+	}
+	c = s.Clone()
+	s.Set(0, -1)
+	s.Pop()
+	c.Push(pushCount)
+
+	if s.Len() != pushCount-1 || c.Len() != pushCount+1 {
+		t.Errorf("Expected: %d and %d; Got: %d and %d", pushCount-1, pushCount+1, s.Len(), c.Len())
+	}
+	for i := pushCount; i >= 0; i-- {
+		if v, ok := c.Pop(); !ok || v != i {
+			t.Errorf("Expected: %d; Got: %d", i, v)
+		}
+	}
+	if v, ok := s.Get(0); !ok || v != -1 {
+		t.Errorf("Expected: -1; Got: %d", v)
+	}
+}
+
+func TestSnapshotShouldNotChangeWhenStackChanges(t *testing.T) {
+	var s stack.Stack[int]
+	empty := s.Snapshot()
+	for i := 0; i < pushCount; i++ {
+		s.Push(i)
+	}
+
+	want := s.ToSlice()
+	sn := s.Snapshot()
+	s.Set(0, -1)
+	s.Set(pushCount-1, -1)
+	s.Pop()
+	s.Push(-2)
+	s.PushAll(make([]int, pushCount)...)
+	s.PopN(make([]int, pushCount*2), pushCount*2)
+	s.Push(-3)
+
+	if empty.Len() != 0 {
+		t.Errorf("Expected: 0; Got: %d", empty.Len())
+	}
+	if sn.Len() != pushCount {
+		t.Errorf("Expected: %d; Got: %d", pushCount, sn.Len())
+	}
+	if got := sn.ToSlice(); !slices.Equal(got, want) {
+		t.Errorf("Expected: %d values in push order; Got: %v", pushCount, got)
+	}
+	if v, ok := sn.Back(); !ok || v != pushCount-1 {
+		t.Errorf("Expected: %d; Got: %d", pushCount-1, v)
+	}
+	if got, want := s.ToSlice(), []int{-3}; !slices.Equal(got, want) {
+		t.Errorf("Expected: %v; Got: %v", want, got)
+	}
+}
+
+func TestSnapshotShouldSupportAllReadOperations(t *testing.T) {
+	s := stack.FromSlice([]int{1, 2, 3})
+	sn := s.Snapshot()
+	s.Init()
+
+	if v, ok := sn.At(0); !ok || v != 3 {
+		t.Errorf("Expected: 3; Got: %d", v)
+	}
+	if v, ok := sn.Get(0); !ok || v != 1 {
+		t.Errorf("Expected: 1; Got: %d", v)
+	}
+	dst := make([]int, 3)
+	if c := sn.PeekN(dst, 3); c != 3 || !slices.Equal(dst, []int{3, 2, 1}) {
+		t.Errorf("Expected: 3, [3 2 1]; Got: %d, %v", c, dst)
+	}
+	var all, backward []int
+	for _, v := range sn.All() {
+		all = append(all, v)
+	}
+	for _, v := range sn.Backward() {
+		backward = append(backward, v)
+	}
+	if !slices.Equal(all, []int{3, 2, 1}) || !slices.Equal(backward, []int{1, 2, 3}) {
+		t.Errorf("Expected: [3 2 1] and [1 2 3]; Got: %v and %v", all, backward)
+	}
+	if got := sn.AppendTo([]int{0}); !slices.Equal(got, []int{0, 1, 2, 3}) {
+		t.Errorf("Expected: [0 1 2 3]; Got: %v", got)
+	}
+
+	c := sn.Clone()
+	c.Push(4)
+	if c.Len() != 4 || sn.Len() != 3 {
+		t.Errorf("Expected: 4 and 3; Got: %d and %d", c.Len(), sn.Len())
+	}
+}
+
+func TestSnapshotShouldBeReadableWhileStackChanges(t *testing.T) {
+	var s stack.Stack[int]
+	for i := 0; i < pushCount; i++ {
+		s.Push(i)
+	}
+
+	sn := s.Snapshot()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for k := 0; k < 10; k++ {
+			for i, v := range sn.Backward() {
+				if v != pushCount-1-i {
+					t.Errorf("Expected: %d; Got: %d", pushCount-1-i, v)
+					return
+				}
+			}
+		}
+	}()
+	for i := 0; i < pushCount; i++ {
+		s.Set(i, -i)
+		s.Pop()
+		s.Push(i)
+	}
+	<-done
+}
+
+func TestSnapshotShouldBeReadableWhileStackDrains(t *testing.T) {
+	var s stack.Stack[int]
+	for i := 0; i < pushCount; i++ {
+		s.Push(i)
+	}
+
+	sn := s.Snapshot()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for k := 0; k < 10; k++ {
+			for i, v := range sn.All() {
+				if v != pushCount-1-i {
+					t.Errorf("Expected: %d; Got: %d", pushCount-1-i, v)
+					return
+				}
+			}
+		}
+	}()
+	for s.Len() > 0 {
+		s.Pop()
+	}
+	s.Push(-1)
+	<-done
+	if sn.Len() != pushCount {
+		t.Errorf("Expected: %d; Got: %d", pushCount, sn.Len())
+	}
+}
+
+func TestPersistentStackShouldKeepPreviousVersionsUnchanged(t *testing.T) {
+	var versions []stack.PersistentStack[int]
+	var ps stack.PersistentStack[int]
+	for i := 0; i < pushCount; i++ {
+		versions = append(versions, ps)
+		ps = ps.Push(i)
+	}
+	versions = append(versions, ps)
+
+	// Branch out of every version, then pop the latest one.
+	for i, v := range versions {
+		versions[i] = v.Push(-i)
+	}
+	for i := pushCount - 1; i >= 0; i-- {
+		var v int
+		var ok bool
+		if ps, v, ok = ps.Pop(); !ok || v != i {
+			t.Errorf("Expected: %d; Got: %d", i, v)
+		}
+	}
+	if _, _, ok := ps.Pop(); ok {
+		t.Error("Expected: false as the stack is empty; Got: true")
+	}
+
+	for i, ps := range versions {
+		if ps.Len() != i+1 {
+			t.Errorf("Expected: %d; Got: %d", i+1, ps.Len())
+		}
+		if v, ok := ps.Back(); !ok || v != -i {
+			t.Errorf("Expected: %d; Got: %d", -i, v)
+		}
+		if i > 0 {
+			if v, ok := ps.At(1); !ok || v != i-1 {
+				t.Errorf("Expected: %d; Got: %d", i-1, v)
+			}
+		}
+	}
+}
+
+func TestPersistentStackWithZeroValueShouldReturnAsEmpty(t *testing.T) {
+	var ps stack.PersistentStack[int]
+	if ps.Len() != 0 {
+		t.Errorf("Expected: 0; Got: %d", ps.Len())
+	}
+	if _, ok := ps.Back(); ok {
+		t.Error("Expected: false as the stack is empty; Got: true")
+	}
+	if _, ok := ps.At(0); ok {
+		t.Error("Expected: false as the stack is empty; Got: true")
+	}
+	if got := ps.ToSlice(); len(got) != 0 {
+		t.Errorf("Expected: empty slice; Got: %v", got)
+	}
+
+	ps, _, _ = ps.Push(1).Pop()
+	if ps != (stack.PersistentStack[int]{}) {
+		t.Error("Expected: zero value after popping all values")
+	}
+}
+
+func TestPersistentStackShouldReadValuesInOrder(t *testing.T) {
+	var ps stack.PersistentStack[int]
+	want := make([]int, pushCount)
+	for i := range want {
+		want[i] = i
+		ps = ps.Push(i)
+	}
+
+	if got := ps.ToSlice(); !slices.Equal(got, want) {
+		t.Errorf("Expected: %d values in push order; Got: %v", pushCount, got)
+	}
+	if got := ps.AppendTo([]int{-1}); got[0] != -1 || !slices.Equal(got[1:], want) {
+		t.Errorf("Expected: -1 followed by %d values in push order; Got: %v", pushCount, got)
+	}
+	for i, v := range ps.All() {
+		if v != pushCount-1-i {
+			t.Errorf("Expected: %d; Got: %d", pushCount-1-i, v)
+		}
+		if v2, ok := ps.At(i); !ok || v2 != v {
+			t.Errorf("Expected: %d; Got: %d", v, v2)
+		}
+		if i == 10 {
+			break
+		}
+	}
+	if _, ok := ps.At(-1); ok {
+		t.Error("Expected: false as the depth is out of range; Got: true")
+	}
+	if _, ok := ps.At(pushCount); ok {
+		t.Error("Expected: false as the depth is out of range; Got: true")
+	}
+}
+
+func TestPersistentStackShouldSupportConcurrentPushes(t *testing.T) {
+	var base stack.PersistentStack[int]
+	for i := 0; i < 100; i++ {
+		base = base.Push(i)
+	}
+
+	const workers = 8
+	results := make(chan stack.PersistentStack[int], workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			ps := base
+			for i := 0; i < pushCount; i++ {
+				ps = ps.Push(w)
+			}
+			results <- ps
+		}()
+	}
+	for w := 0; w < workers; w++ {
+		ps := <-results
+		got := ps.ToSlice()
+		if !slices.Equal(got[:100], base.ToSlice()) {
+			t.Errorf("Expected: base values; Got: %v", got[:100])
+		}
+		w := got[100]
+		for _, v := range got[100:] {
+			if v != w {
+				t.Fatalf("Expected: %d; Got: %d", w, v)
+			}
+		}
+	}
+}
+
+func TestSyncStackShouldBehaveLikeStack(t *testing.T) {
+	var s stack.SyncStack[int]
+	s.PushAll(1, 2)
+	s.PushSeq(slices.Values([]int{3, 4}))
+	s.Push(5)
+
+	if s.Len() != 5 {
+		t.Errorf("Expected: 5; Got: %d", s.Len())
+	}
+	if v, ok := s.Back(); !ok || v != 5 {
+		t.Errorf("Expected: 5; Got: %d", v)
+	}
+	if v, ok := s.At(1); !ok || v != 4 {
+		t.Errorf("Expected: 4; Got: %d", v)
+	}
+	if !s.Set(0, 0) {
+		t.Error("Expected: true; Got: false")
+	}
+	if v, ok := s.Get(0); !ok || v != 0 {
+		t.Errorf("Expected: 0; Got: %d", v)
+	}
+	dst := make([]int, 2)
+	if c := s.PeekN(dst, 2); c != 2 || !slices.Equal(dst, []int{5, 4}) {
+		t.Errorf("Expected: 2, [5 4]; Got: %d, %v", c, dst)
+	}
+	if got := s.ToSlice(); !slices.Equal(got, []int{0, 2, 3, 4, 5}) {
+		t.Errorf("Expected: [0 2 3 4 5]; Got: %v", got)
+	}
+	if got := s.AppendTo([]int{-1}); !slices.Equal(got, []int{-1, 0, 2, 3, 4, 5}) {
+		t.Errorf("Expected: [-1 0 2 3 4 5]; Got: %v", got)
+	}
+
+	c := s.Clone()
+	sn := s.Snapshot()
+	if v, ok := s.Pop(); !ok || v != 5 {
+		t.Errorf("Expected: 5; Got: %d", v)
+	}
+	if c := s.PopN(dst, 2); c != 2 || !slices.Equal(dst, []int{4, 3}) {
+		t.Errorf("Expected: 2, [4 3]; Got: %d, %v", c, dst)
+	}
+	if got := slices.Collect(s.Drain()); !slices.Equal(got, []int{2, 0}) {
+		t.Errorf("Expected: [2 0]; Got: %v", got)
+	}
+	if c.Len() != 5 || sn.Len() != 5 {
+		t.Errorf("Expected: 5 and 5; Got: %d and %d", c.Len(), sn.Len())
+	}
+
+	c.Init()
+	if _, ok := c.Pop(); ok {
+		t.Error("Expected: false as the stack is empty; Got: true")
+	}
+}
+
+func TestSyncStackIteratorsShouldWalkSnapshot(t *testing.T) {
+	s := stack.NewSync[int]()
+	s.PushAll(1, 2, 3)
+
+	var all, backward []int
+	for _, v := range s.All() {
+		all = append(all, v)
+		s.Push(v) // Doesn't panic nor change the values being iterated.
+	}
+	for _, v := range s.Backward() {
+		backward = append(backward, v)
+		s.Pop()
+	}
+	if !slices.Equal(all, []int{3, 2, 1}) {
+		t.Errorf("Expected: [3 2 1]; Got: %v", all)
+	}
+	if !slices.Equal(backward, []int{1, 2, 3, 3, 2, 1}) {
+		t.Errorf("Expected: [1 2 3 3 2 1]; Got: %v", backward)
+	}
+	if s.Len() != 0 {
+		t.Errorf("Expected: 0; Got: %d", s.Len())
+	}
+}
+
+func TestSyncStackPopIfShouldPopOnlyWhenPredicateHolds(t *testing.T) {
+	var s stack.SyncStack[int]
+	even := func(v int) bool { return v%2 == 0 }
+	if _, ok := s.PopIf(even); ok {
+		t.Error("Expected: false as the stack is empty; Got: true")
+	}
+
+	s.PushAll(2, 3)
+	if v, ok := s.PopIf(even); ok || v != 0 {
+		t.Errorf("Expected: 0 and false; Got: %d and %t", v, ok)
+	}
+	if s.Len() != 2 {
+		t.Errorf("Expected: 2; Got: %d", s.Len())
+	}
+	s.Pop()
+	if v, ok := s.PopIf(even); !ok || v != 2 {
+		t.Errorf("Expected: 2 and true; Got: %d and %t", v, ok)
+	}
+}
+
+func TestSyncStackPushIfLenBelowShouldNotExceedLimitUnderConcurrency(t *testing.T) {
+	const limit, workers = 100, 8
+	var s stack.SyncStack[int]
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < limit; i++ {
+				s.PushIfLenBelow(limit, i)
+			}
+		}()
+	}
+	wg.Wait()
+	if s.Len() != limit {
+		t.Errorf("Expected: %d; Got: %d", limit, s.Len())
+	}
+	if s.PushIfLenBelow(limit, 0) {
+		t.Error("Expected: false as the stack is full; Got: true")
+	}
+}
+
+func TestSyncStackShouldBeSafeForConcurrentUse(t *testing.T) {
+	const workers = 8
+	var s stack.SyncStack[int]
+
+	var wg sync.WaitGroup
+	var popped [workers][]int
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < pushCount; i++ {
+				s.Push(i)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for len(popped[w]) < pushCount {
+				if v, ok := s.Pop(); ok {
+					popped[w] = append(popped[w], v)
+				}
+				for range s.All() {
+					break
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	counts := make([]int, pushCount)
+	for _, p := range popped {
+		for _, v := range p {
+			counts[v]++
+		}
+	}
+	for v, c := range counts {
+		if c != workers {
+			t.Errorf("Expected: value %d popped %d times; Got: %d", v, workers, c)
+		}
+	}
+}
+
+func TestConcurrentStackShouldRetrieveAllElementsInOrder(t *testing.T) {
+	var s stack.ConcurrentStack[int]
+	if _, ok := s.Pop(); ok {
+		t.Error("Expected: false as the stack is empty; Got: true")
+	}
+	if _, ok := s.Back(); ok {
+		t.Error("Expected: false as the stack is empty; Got: true")
+	}
+
+	s.Push(0)
+	s.PushAll()
+	s.PushAll(1, 2, 3)
+	s.Push(4)
+	if s.Len() != 5 {
+		t.Errorf("Expected: 5; Got: %d", s.Len())
+	}
+	if v, ok := s.Back(); !ok || v != 4 {
+		t.Errorf("Expected: 4; Got: %d", v)
+	}
+
+	var all []int
+	for _, v := range s.All() {
+		all = append(all, v)
+	}
+	if !slices.Equal(all, []int{4, 3, 2, 1, 0}) {
+		t.Errorf("Expected: [4 3 2 1 0]; Got: %v", all)
+	}
+	for i := 4; i >= 0; i-- {
+		if v, ok := s.Pop(); !ok || v != i {
+			t.Errorf("Expected: %d; Got: %d", i, v)
+		}
+		if s.Len() != i {
+			t.Errorf("Expected: %d; Got: %d", i, s.Len())
+		}
+	}
+	if _, ok := s.Pop(); ok {
+		t.Error("Expected: false as the stack is empty; Got: true")
+	}
+}
+
+func TestConcurrentStackAllShouldStopOnBreak(t *testing.T) {
+	s := stack.NewConcurrent[int]()
+	s.PushAll(1, 2, 3)
+
+	for _, v := range s.All() {
+		if v != 3 {
+			t.Errorf("Expected: 3; Got: %d", v)
+		}
+		break
+	}
+}
+
+func TestConcurrentStackShouldBeSafeForConcurrentUse(t *testing.T) {
+	const workers = 8
+	var s stack.ConcurrentStack[int]
+
+	var wg sync.WaitGroup
+	var popped [workers][]int
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < pushCount; i += 4 {
+				s.Push(i)
+				s.PushAll(i+1, i+2, i+3)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for len(popped[w]) < pushCount {
+				if v, ok := s.Pop(); ok {
+					popped[w] = append(popped[w], v)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	counts := make([]int, pushCount)
+	for _, p := range popped {
+		for _, v := range p {
+			counts[v]++
+		}
+	}
+	for v, c := range counts {
+		if c != workers {
+			t.Errorf("Expected: value %d popped %d times; Got: %d", v, workers, c)
+		}
+	}
+	if s.Len() != 0 {
+		t.Errorf("Expected: 0; Got: %d", s.Len())
+	}
+}
+
+func TestEliminationStackShouldRetrieveAllElementsInOrder(t *testing.T) {
+	var s stack.EliminationStack[int]
+	if _, ok := s.Pop(); ok {
+		t.Error("Expected: false as the stack is empty; Got: true")
+	}
+
+	s.Push(0)
+	s.PushAll(1, 2)
+	if s.Len() != 3 {
+		t.Errorf("Expected: 3; Got: %d", s.Len())
+	}
+	if v, ok := s.Back(); !ok || v != 2 {
+		t.Errorf("Expected: 2; Got: %d", v)
+	}
+	var all []int
+	for _, v := range s.All() {
+		all = append(all, v)
+	}
+	if !slices.Equal(all, []int{2, 1, 0}) {
+		t.Errorf("Expected: [2 1 0]; Got: %v", all)
+	}
+	for i := 2; i >= 0; i-- {
+		if v, ok := s.Pop(); !ok || v != i {
+			t.Errorf("Expected: %d; Got: %d", i, v)
+		}
+	}
+	if _, ok := s.Pop(); ok {
+		t.Error("Expected: false as the stack is empty; Got: true")
+	}
+}
+
+func TestEliminationStackShouldBeSafeForConcurrentUse(t *testing.T) {
+	const workers = 16
+	s := stack.NewElimination[int]()
+
+	var wg sync.WaitGroup
+	var popped [workers][]int
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < pushCount; i++ {
+				s.Push(i)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for len(popped[w]) < pushCount {
+				if v, ok := s.Pop(); ok {
+					popped[w] = append(popped[w], v)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	counts := make([]int, pushCount)
+	for _, p := range popped {
+		for _, v := range p {
+			counts[v]++
+		}
+	}
+	for v, c := range counts {
+		if c != workers {
+			t.Errorf("Expected: value %d popped %d times; Got: %d", v, workers, c)
+		}
+	}
+	if s.Len() != 0 {
+		t.Errorf("Expected: 0; Got: %d", s.Len())
+	}
+}
+
+func TestBlockingStackPopWaitShouldWaitForPush(t *testing.T) {
+	s := stack.NewBlocking[int]()
+	got := make(chan int)
+	for i := 0; i < 3; i++ {
+		go func() {
+			v, err := s.PopWait(context.Background())
+			if err != nil {
+				t.Errorf("Expected: no error; Got: %v", err)
+			}
+			got <- v
+		}()
+	}
+
+	s.Push(1)
+	s.PushAll(2, 3)
+	sum := <-got + <-got + <-got
+	if sum != 6 {
+		t.Errorf("Expected: 6; Got: %d", sum)
+	}
+	if s.Len() != 0 {
+		t.Errorf("Expected: 0; Got: %d", s.Len())
+	}
+}
+
+func TestBlockingStackPopWaitShouldReturnAvailableValuesInOrder(t *testing.T) {
+	var s stack.BlockingStack[int]
+	s.PushAll(1, 2)
+	s.Push(3)
+
+	if v, ok := s.Back(); !ok || v != 3 {
+		t.Errorf("Expected: 3; Got: %d", v)
+	}
+	for i := 3; i >= 1; i-- {
+		if v, err := s.PopWait(context.Background()); err != nil || v != i {
+			t.Errorf("Expected: %d; Got: %d, %v", i, v, err)
+		}
+	}
+}
+
+func TestBlockingStackPopWaitShouldReturnContextError(t *testing.T) {
+	var s stack.BlockingStack[int]
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	go func() {
+		_, err := s.PopWait(ctx)
+		errs <- err
+	}()
+
+	cancel()
+	if err := <-errs; err != context.Canceled {
+		t.Errorf("Expected: %v; Got: %v", context.Canceled, err)
+	}
+
+	// Values pushed after a consumer gave up are kept.
+	s.Push(1)
+	if v, ok := s.TryPop(); !ok || v != 1 {
+		t.Errorf("Expected: 1; Got: %d", v)
+	}
+}
+
+func TestBlockingStackTryPopShouldNotBlock(t *testing.T) {
+	var s stack.BlockingStack[int]
+	if v, ok := s.TryPop(); ok || v != 0 {
+		t.Errorf("Expected: 0 and false; Got: %d and %t", v, ok)
+	}
+	s.Push(1)
+	if v, ok := s.TryPop(); !ok || v != 1 {
+		t.Errorf("Expected: 1 and true; Got: %d and %t", v, ok)
+	}
+}
+
+func TestBlockingStackPopTimeoutShouldTimeOut(t *testing.T) {
+	var s stack.BlockingStack[int]
+	start := time.Now()
+	if _, err := s.PopTimeout(10 * time.Millisecond); err != context.DeadlineExceeded {
+		t.Errorf("Expected: %v; Got: %v", context.DeadlineExceeded, err)
+	}
+	if d := time.Since(start); d < 10*time.Millisecond {
+		t.Errorf("Expected: to wait at least 10ms; Got: %v", d)
+	}
+
+	go func() {
+		time.Sleep(time.Millisecond)
+		s.Push(1)
+	}()
+	if v, err := s.PopTimeout(time.Minute); err != nil || v != 1 {
+		t.Errorf("Expected: 1; Got: %d, %v", v, err)
+	}
+}
+
+func TestBlockingStackShouldBeSafeForConcurrentUse(t *testing.T) {
+	const workers = 8
+	var s stack.BlockingStack[int]
+
+	var wg sync.WaitGroup
+	var popped [workers][]int
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < pushCount; i++ {
+				s.Push(i)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for len(popped[w]) < pushCount {
+				v, err := s.PopWait(context.Background())
+				if err != nil {
+					t.Errorf("Expected: no error; Got: %v", err)
+					return
+				}
+				popped[w] = append(popped[w], v)
+			}
+		}()
+	}
+	wg.Wait()
+
+	counts := make([]int, pushCount)
+	for _, p := range popped {
+		for _, v := range p {
+			counts[v]++
+		}
+	}
+	for v, c := range counts {
+		if c != workers {
+			t.Errorf("Expected: value %d popped %d times; Got: %d", v, workers, c)
+		}
+	}
+}
+
+func TestBlockingStackCloseShouldRejectPushes(t *testing.T) {
+	var s stack.BlockingStack[int]
+	if err := s.Push(1); err != nil {
+		t.Errorf("Expected: no error; Got: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("Expected: no error; Got: %v", err)
+	}
+	if !s.Closed() {
+		t.Error("Expected: closed stack")
+	}
+	if err := s.Close(); err != stack.ErrClosed {
+		t.Errorf("Expected: %v; Got: %v", stack.ErrClosed, err)
+	}
+	if err := s.Push(2); err != stack.ErrClosed {
+		t.Errorf("Expected: %v; Got: %v", stack.ErrClosed, err)
+	}
+	if err := s.PushAll(2, 3); err != stack.ErrClosed {
+		t.Errorf("Expected: %v; Got: %v", stack.ErrClosed, err)
+	}
+	if s.Len() != 1 {
+		t.Errorf("Expected: 1; Got: %d", s.Len())
+	}
+}
+
+func TestBlockingStackCloseShouldLetConsumersDrain(t *testing.T) {
+	var s stack.BlockingStack[int]
+	s.PushAll(1, 2, 3)
+	s.Close()
+
+	for i := 3; i >= 1; i-- {
+		if v, err := s.PopWait(context.Background()); err != nil || v != i {
+			t.Errorf("Expected: %d; Got: %d, %v", i, v, err)
+		}
+	}
+	if v, err := s.PopWait(context.Background()); err != stack.ErrClosed || v != 0 {
+		t.Errorf("Expected: 0, %v; Got: %d, %v", stack.ErrClosed, v, err)
+	}
+	if _, err := s.PopTimeout(time.Minute); err != stack.ErrClosed {
+		t.Errorf("Expected: %v; Got: %v", stack.ErrClosed, err)
+	}
+	if _, ok := s.TryPop(); ok {
+		t.Error("Expected: no value")
+	}
+}
+
+func TestBlockingStackCloseShouldWakeUpAllConsumers(t *testing.T) {
+	const consumers = 4
+	var s stack.BlockingStack[int]
+	errs := make(chan error)
+	for i := 0; i < consumers; i++ {
+		go func() {
+			_, err := s.PopWait(context.Background())
+			errs <- err
+		}()
+	}
+
+	time.Sleep(time.Millisecond)
+	s.Close()
+	for i := 0; i < consumers; i++ {
+		if err := <-errs; err != stack.ErrClosed {
+			t.Errorf("Expected: %v; Got: %v", stack.ErrClosed, err)
+		}
+	}
+}
+
+func TestBlockingStackCloseNowShouldReturnValuesLeft(t *testing.T) {
+	var s stack.BlockingStack[int]
+	s.PushAll(1, 2, 3)
+	s.Close()
+	if v, _ := s.PopWait(context.Background()); v != 3 {
+		t.Errorf("Expected: 3; Got: %d", v)
+	}
+
+	if vs := s.CloseNow(); !slices.Equal(vs, []int{1, 2}) {
+		t.Errorf("Expected: [1 2]; Got: %v", vs)
+	}
+	if s.Len() != 0 {
+		t.Errorf("Expected: 0; Got: %d", s.Len())
+	}
+	if _, err := s.PopWait(context.Background()); err != stack.ErrClosed {
+		t.Errorf("Expected: %v; Got: %v", stack.ErrClosed, err)
+	}
+	if vs := s.CloseNow(); len(vs) != 0 {
+		t.Errorf("Expected: no values; Got: %v", vs)
+	}
+}
+
+func TestBlockingStackNotEmptyShouldBeLevelTriggered(t *testing.T) {
+	var s stack.BlockingStack[int]
+	ready := s.NotEmpty()
+	select {
+	case <-ready:
+		t.Fatal("Expected: empty stack not to be ready")
+	default:
+	}
+
+	s.PushAll(1, 2)
+	<-ready
+	if v, ok := s.TryPop(); !ok || v != 2 {
+		t.Errorf("Expected: 2; Got: %d", v)
+	}
+	// The stack still has values, so it's still ready.
+	<-s.NotEmpty()
+	if v, err := s.PopWait(context.Background()); err != nil || v != 1 {
+		t.Errorf("Expected: 1; Got: %d, %v", v, err)
+	}
+
+	ready = s.NotEmpty()
+	select {
+	case <-ready:
+		t.Fatal("Expected: empty stack not to be ready")
+	default:
+	}
+	s.Close()
+	<-ready
+	<-s.NotEmpty()
+	if _, err := s.PopWait(context.Background()); err != stack.ErrClosed {
+		t.Errorf("Expected: %v; Got: %v", stack.ErrClosed, err)
+	}
+}
+
+func TestBlockingStackNotEmptyShouldWorkInSelect(t *testing.T) {
+	var s stack.BlockingStack[int]
+	go func() {
+		time.Sleep(time.Millisecond)
+		s.PushAll(1, 2, 3)
+	}()
+
+	timeout := time.After(time.Minute)
+	var got []int
+	for len(got) < 3 {
+		select {
+		case <-s.NotEmpty():
+			if v, ok := s.TryPop(); ok {
+				got = append(got, v)
+			}
+		case <-timeout:
+			t.Fatal("Expected: values before the timeout")
+		}
+	}
+	if !slices.Equal(got, []int{3, 2, 1}) {
+		t.Errorf("Expected: [3 2 1]; Got: %v", got)
+	}
+}
+
+func TestBoundedStackRejectShouldReturnErrFull(t *testing.T) {
+	s := stack.NewBounded[int](2, stack.Reject)
+	if s.Max() != 2 {
+		t.Errorf("Expected: 2; Got: %d", s.Max())
+	}
+	if err := s.PushAll(1, 2); err != nil {
+		t.Errorf("Expected: no error; Got: %v", err)
+	}
+	if err := s.Push(3); err != stack.ErrFull {
+		t.Errorf("Expected: %v; Got: %v", stack.ErrFull, err)
+	}
+	s.TryPop()
+	if err := s.PushAll(3, 4); err != stack.ErrFull {
+		t.Errorf("Expected: %v; Got: %v", stack.ErrFull, err)
+	}
+	if err := s.Push(3); err != nil {
+		t.Errorf("Expected: no error; Got: %v", err)
+	}
+	for i := 3; i >= 1; i -= 2 {
+		if v, ok := s.TryPop(); !ok || v != i {
+			t.Errorf("Expected: %d; Got: %d", i, v)
+		}
+	}
+	if s.Dropped() != 0 {
+		t.Errorf("Expected: 0; Got: %d", s.Dropped())
+	}
+}
+
+func TestBoundedStackDropBottomShouldDropOldestValues(t *testing.T) {
+	const max = 512 + 10
+	s := stack.NewBounded[int](max, stack.DropBottom)
+	for i := 0; i < pushCount; i++ {
+		if err := s.Push(i); err != nil {
+			t.Errorf("Expected: no error; Got: %v", err)
+		}
+	}
+	if s.Len() != max {
+		t.Errorf("Expected: %d; Got: %d", max, s.Len())
+	}
+	if s.Dropped() != pushCount-max {
+		t.Errorf("Expected: %d; Got: %d", pushCount-max, s.Dropped())
+	}
+	for i := pushCount - 1; i >= pushCount-max; i-- {
+		if v, ok := s.TryPop(); !ok || v != i {
+			t.Fatalf("Expected: %d; Got: %d", i, v)
+		}
+	}
+	if s.Len() != 0 {
+		t.Errorf("Expected: 0; Got: %d", s.Len())
+	}
+}
+
+func TestBoundedStackDropBottomShouldKeepNewestValuesOfPushAll(t *testing.T) {
+	s := stack.NewBounded[int](3, stack.DropBottom)
+	s.PushAll(1, 2)
+	s.PushAll(3, 4, 5, 6, 7)
+	if s.Dropped() != 4 {
+		t.Errorf("Expected: 4; Got: %d", s.Dropped())
+	}
+	for i := 7; i >= 5; i-- {
+		if v, ok := s.TryPop(); !ok || v != i {
+			t.Errorf("Expected: %d; Got: %d", i, v)
+		}
+	}
+}
+
+func TestBoundedStackBlockShouldWaitForRoom(t *testing.T) {
+	s := stack.NewBounded[int](2, stack.Block)
+	s.PushAll(1, 2)
+	errs := make(chan error)
+	go func() {
+		errs <- s.PushAll(3, 4)
+	}()
+	go func() {
+		errs <- s.Push(5)
+	}()
+
+	time.Sleep(time.Millisecond)
+	if s.Len() != 2 {
+		t.Errorf("Expected: 2; Got: %d", s.Len())
+	}
+	var got []int
+	for len(got) < 5 {
+		v, err := s.PopWait(context.Background())
+		if err != nil {
+			t.Fatalf("Expected: no error; Got: %v", err)
+		}
+		got = append(got, v)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("Expected: no error; Got: %v", err)
+		}
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, []int{1, 2, 3, 4, 5}) {
+		t.Errorf("Expected: [1 2 3 4 5]; Got: %v", got)
+	}
+	if err := s.PushAll(1, 2, 3); err != stack.ErrFull {
+		t.Errorf("Expected: %v; Got: %v", stack.ErrFull, err)
+	}
+}
+
+func TestBoundedStackBlockShouldReturnContextError(t *testing.T) {
+	s := stack.NewBounded[int](1, stack.Block)
+	s.Push(1)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := s.PushWait(ctx, 2); err != context.DeadlineExceeded {
+		t.Errorf("Expected: %v; Got: %v", context.DeadlineExceeded, err)
+	}
+
+	errs := make(chan error)
+	go func() {
+		errs <- s.Push(2)
+	}()
+	time.Sleep(time.Millisecond)
+	s.Close()
+	if err := <-errs; err != stack.ErrClosed {
+		t.Errorf("Expected: %v; Got: %v", stack.ErrClosed, err)
+	}
+	if v, ok := s.TryPop(); !ok || v != 1 {
+		t.Errorf("Expected: 1; Got: %d", v)
+	}
+}
+
+func TestNewBoundedShouldPanicOnNonPositiveBound(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected: panic")
+		}
+	}()
+	stack.NewBounded[int](0, stack.Reject)
+}
+
+func TestSizedStackShouldTrackBytes(t *testing.T) {
+	s := stack.NewSized(10, func(v string) int { return len(v) }, stack.Reject)
+	if err := s.PushAll("abc", "de"); err != nil {
+		t.Errorf("Expected: no error; Got: %v", err)
+	}
+	if err := s.Push("fgh"); err != nil {
+		t.Errorf("Expected: no error; Got: %v", err)
+	}
+	if s.Bytes() != 8 {
+		t.Errorf("Expected: 8; Got: %d", s.Bytes())
+	}
+	if err := s.Push("ijk"); err != stack.ErrFull {
+		t.Errorf("Expected: %v; Got: %v", stack.ErrFull, err)
+	}
+	if err := s.Push("ij"); err != nil {
+		t.Errorf("Expected: no error; Got: %v", err)
+	}
+	if v, ok := s.TryPop(); !ok || v != "ij" {
+		t.Errorf("Expected: ij; Got: %s", v)
+	}
+	if v, err := s.PopWait(context.Background()); err != nil || v != "fgh" {
+		t.Errorf("Expected: fgh; Got: %s, %v", v, err)
+	}
+	if s.Bytes() != 5 {
+		t.Errorf("Expected: 5; Got: %d", s.Bytes())
+	}
+	s.CloseNow()
+	if s.Bytes() != 0 {
+		t.Errorf("Expected: 0; Got: %d", s.Bytes())
+	}
+}
+
+func TestSizedStackDropBottomShouldDropOldestValues(t *testing.T) {
+	s := stack.NewSized(10, func(v string) int { return len(v) }, stack.DropBottom)
+	s.PushAll("abc", "de", "fgh")
+	s.Push("ijklm")
+	if s.Bytes() != 10 || s.Dropped() != 1 {
+		t.Errorf("Expected: 10 bytes and 1 dropped; Got: %d and %d", s.Bytes(), s.Dropped())
+	}
+	// Values larger than the budget are dropped right away.
+	s.PushAll("nopqrstuvwxyz", "12")
+	if s.Bytes() != 10 || s.Dropped() != 3 {
+		t.Errorf("Expected: 10 bytes and 3 dropped; Got: %d and %d", s.Bytes(), s.Dropped())
+	}
+	// Values that never fit are rejected rather than dropped.
+	if err := s.Push("nopqrstuvwxyz"); err != stack.ErrFull {
+		t.Errorf("Expected: %v; Got: %v", stack.ErrFull, err)
+	}
+	if err := s.PushAll("12", "nopqrstuvwxyz"); err != stack.ErrFull {
+		t.Errorf("Expected: %v; Got: %v", stack.ErrFull, err)
+	}
+	if s.Len() != 3 || s.Bytes() != 10 || s.Dropped() != 3 {
+		t.Errorf("Expected: 3 values, 10 bytes and 3 dropped; Got: %d, %d and %d", s.Len(), s.Bytes(), s.Dropped())
+	}
+	want := []string{"12", "ijklm", "fgh"}
+	for _, w := range want {
+		if v, ok := s.TryPop(); !ok || v != w {
+			t.Errorf("Expected: %s; Got: %s", w, v)
+		}
+	}
+	if s.Len() != 0 || s.Bytes() != 0 {
+		t.Errorf("Expected: empty stack; Got: %d values and %d bytes", s.Len(), s.Bytes())
+	}
+}
+
+func TestSizedStackBlockShouldWaitForRoom(t *testing.T) {
+	s := stack.NewSized(4, func(v []byte) int { return len(v) }, stack.Block)
+	s.Push([]byte("abc"))
+	errs := make(chan error)
+	go func() {
+		errs <- s.Push([]byte("de"))
+	}()
+
+	time.Sleep(time.Millisecond)
+	if v, ok := s.TryPop(); !ok || string(v) != "abc" {
+		t.Errorf("Expected: abc; Got: %s", v)
+	}
+	if err := <-errs; err != nil {
+		t.Errorf("Expected: no error; Got: %v", err)
+	}
+	if s.Bytes() != 2 {
+		t.Errorf("Expected: 2; Got: %d", s.Bytes())
+	}
+	if err := s.Push([]byte("fghij")); err != stack.ErrFull {
+		t.Errorf("Expected: %v; Got: %v", stack.ErrFull, err)
+	}
+}
+
+func TestPoolShouldReuseNodes(t *testing.T) {
+	const rounds = 3
+	var p stack.Pool[int]
+	for k := 0; k < rounds; k++ {
+		s := p.New()
+		for i := 0; i < pushCount; i++ {
+			s.Push(i)
+		}
+		for i := pushCount - 1; i >= pushCount/2; i-- {
+			if v, ok := s.Pop(); !ok || v != i {
+				t.Fatalf("Expected: %d; Got: %d", i, v)
+			}
+		}
+		s.Init()
+		if s.Len() != 0 {
+			t.Errorf("Expected: 0; Got: %d", s.Len())
+		}
+		for i := 0; i < pushCount; i++ {
+			s.Push(i)
+		}
+		for i := pushCount - 1; i >= 0; i-- {
+			if v, ok := s.Pop(); !ok || v != i {
+				t.Fatalf("Expected: %d; Got: %d", i, v)
+			}
+		}
+	}
+
+	// Every stack needs 3 full-size nodes, the first one included, after
+	// being created and after being cleared.
+	stats := p.Stats()
+	if got := stats.Hits + stats.Misses; got != 3*2*rounds {
+		t.Errorf("Expected: %d nodes taken; Got: %d", 3*2*rounds, got)
+	}
+	if stats.Hits == 0 {
+		t.Error("Expected: nodes reused from the pool")
+	}
+}
+
+func TestPoolShouldReuseNodesOfDifferentSizes(t *testing.T) {
+	var p stack.Pool[int]
+	fill := func(size, nodes int) {
+		s := stack.NewWithOptions[int](stack.WithNodeSize(size), stack.WithPool(&p))
+		for i := 0; i < size*nodes; i++ {
+			s.Push(i)
+		}
+		s.Init()
+	}
+
+	// The nodes of the small stack are pooled after the large stack's, so the
+	// large stack would only find small nodes if they weren't pooled by size.
+	fill(512, 100)
+	fill(16, 200)
+	before := p.Stats()
+	fill(512, 100)
+	if hits := p.Stats().Hits - before.Hits; hits == 0 {
+		t.Errorf("Expected: nodes reused from the pool; Got: %+v", p.Stats())
+	}
+}
+
+func TestPoolShouldNotTakeNodesSharedWithSnapshots(t *testing.T) {
+	p := stack.NewPool[int]()
+	s := p.New()
+	for i := 0; i < pushCount; i++ {
+		s.Push(i)
+	}
+	snap := s.Snapshot()
+	s.Init()
+
+	s2 := p.New()
+	for i := 0; i < pushCount; i++ {
+		s2.Push(-i)
+	}
+	for i := 0; i < pushCount; i++ {
+		if v, ok := snap.Get(i); !ok || v != i {
+			t.Fatalf("Expected: %d; Got: %d", i, v)
+		}
+	}
+}
+
+func TestNewWithOptionsShouldConfigureStack(t *testing.T) {
+	p := stack.NewPool[int]()
+	s := stack.NewWithOptions[int](
+		stack.WithFirstNodeSize(2),
+		stack.WithNodeSize(16),
+		stack.WithSpareNodes(0),
+		stack.WithPool(p),
+	)
+	for i := 0; i < pushCount; i++ {
+		s.Push(i)
+	}
+	for i := 0; i < pushCount; i++ {
+		if v, ok := s.Get(i); !ok || v != i {
+			t.Fatalf("Expected: %d; Got: %d", i, v)
+		}
+	}
+	for i := pushCount - 1; i >= 0; i-- {
+		if v, ok := s.Pop(); !ok || v != i {
+			t.Fatalf("Expected: %d; Got: %d", i, v)
+		}
+	}
+	if stats := p.Stats(); stats.Hits+stats.Misses == 0 {
+		t.Error("Expected: nodes taken from the pool")
+	}
+}
+
+func TestNewWithOptionsShouldLimitFirstNodeToNodeSize(t *testing.T) {
+	s := stack.NewWithOptions[int](stack.WithFirstNodeSize(1000))
+	for i := 0; i < 600; i++ {
+		s.Push(i)
+	}
+	if stats := s.Stats(); stats.FirstNodeCap != 512 {
+		t.Errorf("Expected: first node of 512; Got: %d", stats.FirstNodeCap)
+	}
+	s.Shrink()
+	for i := 599; i >= 0; i-- {
+		if v, ok := s.Pop(); !ok || v != i {
+			t.Fatalf("Expected: %d; Got: %d", i, v)
+		}
+	}
+}
+
+func TestNewWithOptionsShouldPanicOnPoolOfDifferentType(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected: panic")
+		}
+	}()
+	stack.NewWithOptions[int](stack.WithPool(stack.NewPool[string]()))
+}
+
+func TestGrowthPolicyShouldKeepIndexedAccess(t *testing.T) {
+	policies := []stack.GrowthPolicy{
+		stack.GeometricGrowth{Max: 1 << 16},
+		stack.FixedGrowth{Size: 100},
+		&stack.AdaptiveGrowth{},
+	}
+	for _, p := range policies {
+		s := stack.NewWithOptions[int](stack.WithGrowthPolicy(p))
+		for i := 0; i < pushCount; i++ {
+			s.Push(i)
+		}
+		snap := s.Snapshot()
+		for i := 0; i < pushCount; i++ {
+			s.Set(i, -i)
+		}
+		for i := 0; i < pushCount; i++ {
+			if v, ok := s.Get(i); !ok || v != -i {
+				t.Fatalf("Expected: %d; Got: %d", -i, v)
+			}
+			if v, ok := snap.At(i); !ok || v != pushCount-1-i {
+				t.Fatalf("Expected: %d; Got: %d", pushCount-1-i, v)
+			}
+		}
+		for i := pushCount - 1; i >= 0; i-- {
+			if v, ok := s.Pop(); !ok || v != -i {
+				t.Fatalf("Expected: %d; Got: %d", -i, v)
+			}
+		}
+	}
+}
+
+func TestReserveShouldIncreaseCap(t *testing.T) {
+	var s stack.Stack[int]
+	if s.Cap() != 0 {
+		t.Errorf("Expected: 0; Got: %d", s.Cap())
+	}
+	s.Reserve(0)
+	if s.Cap() != 0 {
+		t.Errorf("Expected: 0; Got: %d", s.Cap())
+	}
+	s.Reserve(5)
+	if c := s.Cap(); c < 5 || c > 8 {
+		t.Errorf("Expected: a capacity between 5 and 8; Got: %d", c)
+	}
+	s.Push(1)
+	s.Reserve(pushCount)
+	if c := s.Cap(); c < pushCount+1 {
+		t.Errorf("Expected: a capacity of at least %d; Got: %d", pushCount+1, c)
+	}
+	if v, ok := s.Pop(); !ok || v != 1 || s.Len() != 0 {
+		t.Errorf("Expected: 1 and an empty stack; Got: %d and %d values", v, s.Len())
+	}
+
+	var ss stack.SyncStack[int]
+	ss.Reserve(pushCount)
+	if c := ss.Cap(); c < pushCount {
+		t.Errorf("Expected: a capacity of at least %d; Got: %d", pushCount, c)
+	}
+}
+
+func TestShrinkShouldReduceCap(t *testing.T) {
+	var s stack.Stack[int]
+	s.Shrink()
+	for i := 0; i < pushCount; i++ {
+		s.Push(i)
+	}
+	for s.Len() > 3 {
+		s.Pop()
+	}
+	s.Shrink()
+	if c := s.Cap(); c < 3 || c > 8 {
+		t.Errorf("Expected: a capacity between 3 and 8; Got: %d", c)
+	}
+	for i := 2; i >= 0; i-- {
+		if v, ok := s.Pop(); !ok || v != i {
+			t.Errorf("Expected: %d; Got: %d", i, v)
+		}
+	}
+	s.Shrink()
+	if s.Cap() != 0 {
+		t.Errorf("Expected: 0; Got: %d", s.Cap())
+	}
+
+	var ss stack.SyncStack[int]
+	ss.Reserve(pushCount)
+	ss.Shrink()
+	if ss.Cap() != 0 {
+		t.Errorf("Expected: 0; Got: %d", ss.Cap())
+	}
+}
+
+func TestResetShouldKeepCap(t *testing.T) {
+	var s stack.Stack[int]
+	s.Reset()
+	for i := 0; i < pushCount; i++ {
+		s.Push(i)
+	}
+	c := s.Cap()
+	s.Reset()
+	if s.Len() != 0 || s.Cap() != c {
+		t.Errorf("Expected: 0 values and capacity of %d; Got: %d and %d", c, s.Len(), s.Cap())
+	}
+	if _, ok := s.Pop(); ok {
+		t.Error("Expected: empty stack")
+	}
+	s.Push(1)
+	if v, ok := s.Back(); !ok || v != 1 || s.Len() != 1 {
+		t.Errorf("Expected: 1; Got: %d", v)
+	}
+
+	var ss stack.SyncStack[int]
+	ss.Push(1)
+	ss.Reset()
+	if ss.Len() != 0 {
+		t.Errorf("Expected: 0; Got: %d", ss.Len())
+	}
+}
+
+func TestStatsShouldReportNodesAndCapacity(t *testing.T) {
+	var s stack.Stack[int64]
+	if stats := s.Stats(); stats != (stack.Stats{}) {
+		t.Errorf("Expected: zero stats; Got: %+v", stats)
+	}
+	for i := 0; i < pushCount; i++ {
+		s.Push(int64(i))
+	}
+	stats := s.Stats()
+	if stats.Nodes != 3 || stats.SpareNodes != 0 || stats.Len != pushCount || stats.Cap != pushCount || stats.FirstNodeCap != 512 {
+		t.Errorf("Expected: 3 full nodes of 512; Got: %+v", stats)
+	}
+	if stats.Bytes < pushCount*8 {
+		t.Errorf("Expected: at least %d bytes; Got: %d", pushCount*8, stats.Bytes)
+	}
+
+	for i := 0; i < 512; i++ {
+		s.Pop()
+	}
+	stats = s.Stats()
+	if stats.Nodes != 2 || stats.SpareNodes != 1 || stats.Len != pushCount-512 || stats.Cap != pushCount {
+		t.Errorf("Expected: 2 nodes and 1 spare node; Got: %+v", stats)
+	}
+
+	var ss stack.SyncStack[int64]
+	ss.Push(1)
+	if stats := ss.Stats(); stats.Nodes != 1 || stats.Len != 1 || stats.FirstNodeCap != 8 {
+		t.Errorf("Expected: 1 node of 8; Got: %+v", stats)
+	}
+}
@@ -28,10 +28,11 @@
 package stack_test
 
 import (
+	"flag"
 	"strconv"
 	"testing"
 
-	"github.com/ef-ds/stack"
+	"github.com/ef-ds/stack/v2"
 )
 
 // testData contains the number of items to add to the stacks in each test.
@@ -57,58 +58,177 @@ var (
 
 	fillCount   = 10000
 	refillCount = 10
+	bulkCount   = 256
+
+	// The node size flags configure the stacks under test, so the benchmarks
+	// can be compared across node sizes, e.g. go test -bench=. -nodesize=1024.
+	// Zero keeps the default sizes.
+	firstNodeSize = flag.Int("firstnodesize", 0, "first node size of the benchmarked stacks, or 0 for the default")
+	nodeSize      = flag.Int("nodesize", 0, "node size of the benchmarked stacks, or 0 for the default")
+
+	// The growth flag sets the growth policy of the stacks under test, e.g.
+	// go test -bench=. -growth=adaptive.
+	growth         = flag.String("growth", "", "growth policy of the benchmarked stacks: geometric, fixed, adaptive, or empty for none")
+	growthPolicies = map[string]stack.GrowthPolicy{
+		"geometric": stack.GeometricGrowth{},
+		"fixed":     stack.FixedGrowth{},
+		"adaptive":  &stack.AdaptiveGrowth{},
+	}
 )
 
+// configure configures stack s, which must be empty, with opts and the node
+// size and growth flags. Without flags, it leaves s as is, so stacks created
+// by New don't escape to the heap and the default benchmarks remain comparable
+// with the release baselines in testdata.
+func configure(s *stack.Stack[interface{}], opts ...stack.Option) {
+	if *firstNodeSize > 0 {
+		opts = append(opts, stack.WithFirstNodeSize(*firstNodeSize))
+	}
+	if *nodeSize > 0 {
+		opts = append(opts, stack.WithNodeSize(*nodeSize))
+	}
+	if *growth != "" {
+		p, ok := growthPolicies[*growth]
+		if !ok {
+			panic("unknown growth policy " + *growth)
+		}
+		opts = append(opts, stack.WithGrowthPolicy(p))
+	}
+	if *firstNodeSize > 0 || *nodeSize > 0 || *growth != "" {
+		*s = *stack.NewWithOptions[interface{}](opts...)
+	}
+}
+
 func BenchmarkMicroservice(b *testing.B) {
 	for _, test := range tests {
 		b.Run(strconv.Itoa(test.count), func(b *testing.B) {
+			// microservice takes an interface, so a stack created on each
+			// iteration would escape to the heap and add an allocation the
+			// v1 benchmark never had. Each iteration clears the same stack
+			// with Init instead: its nodes are still allocated on every
+			// iteration, only the Stack value is reused.
+			s := stack.New[interface{}]()
+			configure(s)
 			for n := 0; n < b.N; n++ {
-				s := stack.New()
+				microservice(s, test.count)
+				s.Init()
+			}
+		})
+	}
+}
 
-				// Simulate stable traffic
-				for i := 0; i < test.count; i++ {
-					s.Push(nil)
-					s.Pop()
-				}
+// BenchmarkMicroservicePool runs the BenchmarkMicroservice traffic simulation
+// with stacks that take their nodes from a pool, as when stacks are created
+// once per request.
+func BenchmarkMicroservicePool(b *testing.B) {
+	var p stack.Pool[interface{}]
+	for _, test := range tests {
+		b.Run(strconv.Itoa(test.count), func(b *testing.B) {
+			s := p.New()
+			configure(s, stack.WithPool(&p))
+			for n := 0; n < b.N; n++ {
+				microservice(s, test.count)
+				s.Init() // Return the nodes to the pool.
+			}
+		})
+	}
+}
 
-				// Simulate slowly increasing traffic
-				for i := 0; i < test.count; i++ {
-					s.Push(nil)
-					s.Push(nil)
-					s.Pop()
-				}
+// concurrentStack is implemented by the safe for concurrent use stacks, as well
+// as by Stack.
+type concurrentStack interface {
+	Push(v interface{})
+	Pop() (interface{}, bool)
+	Len() int
+}
 
-				// Simulate slowly decreasing traffic, bringing traffic Front to normal
-				for i := 0; i < test.count; i++ {
-					s.Pop()
-					if s.Len() > 0 {
-						s.Pop()
-					}
-					s.Push(nil)
-				}
+// microservice simulates the traffic of a microservice using stack s, with
+// count operations in each phase.
+func microservice(s concurrentStack, count int) {
+	// Simulate stable traffic
+	for i := 0; i < count; i++ {
+		s.Push(nil)
+		s.Pop()
+	}
 
-				// Simulate quick traffic spike (DDOS attack, etc)
-				for i := 0; i < test.count; i++ {
-					s.Push(nil)
-				}
+	// Simulate slowly increasing traffic
+	for i := 0; i < count; i++ {
+		s.Push(nil)
+		s.Push(nil)
+		s.Pop()
+	}
 
-				// Simulate stable traffic while at high traffic
-				for i := 0; i < test.count; i++ {
-					s.Push(nil)
-					s.Pop()
-				}
+	// Simulate slowly decreasing traffic, bringing traffic Front to normal
+	for i := 0; i < count; i++ {
+		s.Pop()
+		if s.Len() > 0 {
+			s.Pop()
+		}
+		s.Push(nil)
+	}
 
-				// Simulate going Front to normal (DDOS attack fended off)
-				for i := 0; i < test.count; i++ {
-					s.Pop()
-				}
+	// Simulate quick traffic spike (DDOS attack, etc)
+	for i := 0; i < count; i++ {
+		s.Push(nil)
+	}
 
-				// Simulate stable traffic (now that is Front to normal)
-				for i := 0; i < test.count; i++ {
+	// Simulate stable traffic while at high traffic
+	for i := 0; i < count; i++ {
+		s.Push(nil)
+		s.Pop()
+	}
+
+	// Simulate going Front to normal (DDOS attack fended off)
+	for i := 0; i < count; i++ {
+		s.Pop()
+	}
+
+	// Simulate stable traffic (now that is Front to normal)
+	for i := 0; i < count; i++ {
+		s.Push(nil)
+		s.Pop()
+	}
+}
+
+var concurrentStacks = []struct {
+	name string
+	new  func() concurrentStack
+}{
+	{name: "SyncStack", new: func() concurrentStack { return stack.NewSync[interface{}]() }},
+	{name: "ConcurrentStack", new: func() concurrentStack { return stack.NewConcurrent[interface{}]() }},
+	{name: "EliminationStack", new: func() concurrentStack { return stack.NewElimination[interface{}]() }},
+}
+
+// BenchmarkMicroserviceParallel runs the BenchmarkMicroservice traffic simulation
+// from multiple goroutines at once, all sharing the same stack.
+func BenchmarkMicroserviceParallel(b *testing.B) {
+	for _, impl := range concurrentStacks {
+		for _, test := range tests {
+			b.Run(impl.name+"/"+strconv.Itoa(test.count), func(b *testing.B) {
+				s := impl.new()
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						microservice(s, test.count)
+					}
+				})
+			})
+		}
+	}
+}
+
+// BenchmarkContention has all goroutines pushing and popping values from
+// the same stack as fast as they can, which is the worst case for contention
+// on the top of the stack.
+func BenchmarkContention(b *testing.B) {
+	for _, impl := range concurrentStacks {
+		b.Run(impl.name, func(b *testing.B) {
+			s := impl.new()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
 					s.Push(nil)
 					s.Pop()
 				}
-			}
+			})
 		})
 	}
 }
@@ -117,7 +237,8 @@ func BenchmarkFill(b *testing.B) {
 	for _, test := range tests {
 		b.Run(strconv.Itoa(test.count), func(b *testing.B) {
 			for n := 0; n < b.N; n++ {
-				s := stack.New()
+				s := stack.New[interface{}]()
+				configure(s)
 				for i := 0; i < test.count; i++ {
 					s.Push(nil)
 				}
@@ -129,10 +250,29 @@ func BenchmarkFill(b *testing.B) {
 	}
 }
 
+func BenchmarkFillBulk(b *testing.B) {
+	for _, test := range tests {
+		b.Run(strconv.Itoa(test.count), func(b *testing.B) {
+			block := make([]interface{}, bulkCount)
+			for n := 0; n < b.N; n++ {
+				s := stack.New[interface{}]()
+				configure(s)
+				for i := 0; i < test.count; i += bulkCount {
+					s.PushAll(block[:min(bulkCount, test.count-i)]...)
+				}
+				for s.Len() > 0 {
+					s.PopN(block, bulkCount)
+				}
+			}
+		})
+	}
+}
+
 func BenchmarkRefill(b *testing.B) {
 	for _, test := range tests {
 		b.Run(strconv.Itoa(test.count), func(b *testing.B) {
-			q := stack.New()
+			q := stack.New[interface{}]()
+			configure(q)
 			for n := 0; n < b.N; n++ {
 				for n := 0; n < refillCount; n++ {
 					for i := 0; i < test.count; i++ {
@@ -148,7 +288,8 @@ func BenchmarkRefill(b *testing.B) {
 }
 
 func BenchmarkRefillFull(b *testing.B) {
-	s := stack.New()
+	s := stack.New[interface{}]()
+	configure(s)
 	for i := 0; i < fillCount; i++ {
 		s.Push(nil)
 	}
@@ -174,7 +315,8 @@ func BenchmarkRefillFull(b *testing.B) {
 }
 
 func BenchmarkStable(b *testing.B) {
-	s := stack.New()
+	s := stack.New[interface{}]()
+	configure(s)
 	for i := 0; i < fillCount; i++ {
 		s.Push(nil)
 	}
@@ -195,11 +337,34 @@ func BenchmarkStable(b *testing.B) {
 	}
 }
 
+func BenchmarkNodeBoundary(b *testing.B) {
+	// Fill the first node, whatever its size, so every push below moves to a
+	// new node and every pop releases it.
+	s := stack.New[interface{}]()
+	configure(s)
+	for s.Stats().Nodes < 2 {
+		s.Push(nil)
+	}
+	s.Pop()
+
+	for _, test := range tests {
+		b.Run(strconv.Itoa(test.count), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				for i := 0; i < test.count; i++ {
+					s.Push(nil)
+					tmp, tmp2 = s.Pop()
+				}
+			}
+		})
+	}
+}
+
 func BenchmarkSlowIncrease(b *testing.B) {
 	for _, test := range tests {
 		b.Run(strconv.Itoa(test.count), func(b *testing.B) {
 			for n := 0; n < b.N; n++ {
-				s := stack.New()
+				s := stack.New[interface{}]()
+				configure(s)
 				for i := 0; i < test.count; i++ {
 					s.Push(nil)
 					s.Push(nil)
@@ -214,7 +379,8 @@ func BenchmarkSlowIncrease(b *testing.B) {
 }
 
 func BenchmarkSlowDecrease(b *testing.B) {
-	s := stack.New()
+	s := stack.New[interface{}]()
+	configure(s)
 	for _, test := range tests {
 		items := test.count / 2
 		for i := 0; i <= items; i++ {
@@ -0,0 +1,385 @@
+// Copyright (c) 2018 ef-ds
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package stack
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// BlockingStack implements a dynamically growing, optionally bounded,
+// Last-In-First-Out (LIFO) stack data structure that is safe for concurrent use
+// by multiple goroutines and lets consumers block until values are available.
+// The zero value for BlockingStack is an empty stack ready to use.
+//
+// Consumers blocked in PopWait are woken up one at a time, in the order they
+// started waiting, as values are pushed, so they don't need to poll the stack.
+// Once closed, the stack rejects new values and consumers get ErrClosed as
+// soon as the values left in the stack have been popped.
+//
+// The zero value is unbounded; use NewBounded to create a stack that holds up
+// to a maximum number of values.
+type BlockingStack[T any] struct {
+	mu sync.Mutex
+
+	// s holds the stack values.
+	s Stack[T]
+
+	// poppers holds the consumers waiting for values to be pushed.
+	poppers waitq
+
+	// pushers holds the producers waiting for room in a full bounded stack.
+	pushers waitq
+
+	// max holds the maximum number of values in the stack, or 0 if the
+	// stack is unbounded.
+	max int
+
+	// budget holds the maximum total size of the values in the stack, as
+	// measured by sizer, or 0 if the stack size is unbounded.
+	budget int
+
+	// sizer returns the size of a value, if the stack has a budget.
+	sizer Sizer[T]
+
+	// bytes holds the total size of the values in the stack, as measured by sizer.
+	bytes int
+
+	// policy holds what to do with values pushed to a full bounded stack.
+	policy OverflowPolicy
+
+	// dropped counts the values dropped by the DropBottom policy.
+	dropped int
+
+	// closed indicates whether the stack was closed.
+	closed bool
+
+	// ready is the channel returned by NotEmpty. It's created lazily, and it's
+	// closed while the stack has values or is closed. It's dropped when the
+	// stack gets empty, so NotEmpty creates a new one next time.
+	ready chan struct{}
+}
+
+// ErrClosed is returned when pushing to a closed stack, and when popping from
+// a closed stack that has no values left.
+var ErrClosed = errors.New("stack: stack closed")
+
+// waitq implements a FIFO queue of goroutines waiting for a condition,
+// guarded by the mutex of the structure holding the queue.
+// The zero value for waitq is an empty queue ready to use.
+type waitq struct {
+	// ws holds a channel per waiting goroutine, in the order they started waiting.
+	ws []chan struct{}
+}
+
+// NewBlocking returns an initialized blocking stack.
+func NewBlocking[T any]() *BlockingStack[T] {
+	return new(BlockingStack[T])
+}
+
+// Len returns the number of elements of stack s.
+// The complexity is O(1).
+func (s *BlockingStack[T]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.s.Len()
+}
+
+// Back returns the last element of stack s. See Stack.Back.
+// The complexity is O(1).
+func (s *BlockingStack[T]) Back() (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.s.Back()
+}
+
+// Push adds value v to the the back of the stack, waking up a consumer
+// blocked in PopWait, if any. If the stack is closed, Push returns ErrClosed.
+// If the stack is bounded and full, Push applies the stack's OverflowPolicy.
+// The complexity is O(1).
+func (s *BlockingStack[T]) Push(v T) error {
+	return s.push(context.Background(), []T{v})
+}
+
+// PushWait is like Push, but if the stack is full and its OverflowPolicy is
+// Block, PushWait returns ctx.Err() if ctx is done before there's room for v.
+// The complexity is O(1).
+func (s *BlockingStack[T]) PushWait(ctx context.Context, v T) error {
+	return s.push(ctx, []T{v})
+}
+
+// PushAll adds values vs to the back of the stack, in order, waking up as
+// many consumers blocked in PopWait as values were pushed. If the stack is
+// closed, PushAll pushes no values and returns ErrClosed.
+// If the stack is bounded and there's no room for all the values, PushAll
+// applies the stack's OverflowPolicy to them as a whole.
+// The complexity is O(len(vs)).
+func (s *BlockingStack[T]) PushAll(vs ...T) error {
+	return s.push(context.Background(), vs)
+}
+
+// TryPop retrieves and removes the current element from the back of the
+// stack without blocking. The second, bool result indicates whether a valid
+// value was returned; if the stack is empty, false will be returned.
+// The complexity is O(1).
+func (s *BlockingStack[T]) TryPop() (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.s.Pop()
+	if ok {
+		s.popped(v)
+	}
+	return v, ok
+}
+
+// PopWait retrieves and removes the current element from the back of the
+// stack, blocking until a value is pushed if the stack is empty.
+// If ctx is done before a value is available, PopWait returns ctx.Err().
+// If the stack is closed and empty, PopWait returns ErrClosed.
+func (s *BlockingStack[T]) PopWait(ctx context.Context) (T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		if v, ok := s.s.Pop(); ok {
+			s.popped(v)
+			return v, nil
+		}
+		if s.closed {
+			var zero T
+			return zero, ErrClosed
+		}
+		if err := s.wait(ctx, &s.poppers, s.poppers.add()); err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+}
+
+// PopTimeout retrieves and removes the current element from the back of the
+// stack, blocking for up to d until a value is pushed if the stack is empty.
+// If no value is available in time, PopTimeout returns context.DeadlineExceeded.
+// If the stack is closed and empty, PopTimeout returns ErrClosed.
+func (s *BlockingStack[T]) PopTimeout(d time.Duration) (T, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return s.PopWait(ctx)
+}
+
+// Close closes the stack, so further pushes are rejected with ErrClosed, and
+// wakes up all consumers blocked in PopWait and all producers blocked in Push.
+// The values left in the stack can still be popped, which lets consumers
+// drain the stack; once it's empty, PopWait returns ErrClosed. Use CloseNow
+// to discard the values left instead.
+// If the stack is already closed, Close returns ErrClosed.
+func (s *BlockingStack[T]) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	s.closed = true
+	for s.poppers.signal() {
+	}
+	for s.pushers.signal() {
+	}
+	s.setReady()
+	return nil
+}
+
+// CloseNow closes the stack like Close, but also removes the values left in
+// the stack, so consumers get ErrClosed right away. The removed values are
+// returned in the order they were pushed, so they can be handled elsewhere.
+// CloseNow can be called on a stack already closed by Close to stop the
+// consumers from draining it.
+// The complexity is O(n), where n is the number of values left in the stack.
+func (s *BlockingStack[T]) CloseNow() []T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	vs := s.s.ToSlice()
+	s.s.Init()
+	s.bytes = 0
+	for s.poppers.signal() {
+	}
+	for s.pushers.signal() {
+	}
+	s.setReady()
+	return vs
+}
+
+// Closed reports whether the stack was closed.
+// The complexity is O(1).
+func (s *BlockingStack[T]) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// NotEmpty returns a channel that is closed while the stack has values or is
+// closed, so consumers can wait for values in a select statement, along with
+// other channels:
+//
+//	select {
+//	case <-s.NotEmpty():
+//		v, ok := s.TryPop()
+//		...
+//	case <-ctx.Done():
+//		...
+//	}
+//
+// The channel is level-triggered: it's closed as soon as the stack has values,
+// and a new channel is returned once the stack gets empty again. As other
+// consumers may pop the values first, a closed channel is only a hint that
+// values are available; consumers should use TryPop to get them.
+// Values are still popped in strict LIFO order.
+// The complexity is O(1).
+func (s *BlockingStack[T]) NotEmpty() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ready == nil {
+		s.ready = make(chan struct{})
+		if s.s.Len() > 0 || s.closed {
+			close(s.ready)
+		}
+	}
+	return s.ready
+}
+
+// setReady closes the NotEmpty channel, if any and not closed yet.
+// s.mu must be held when calling setReady.
+func (s *BlockingStack[T]) setReady() {
+	if s.ready == nil {
+		return
+	}
+	select {
+	case <-s.ready:
+	default:
+		close(s.ready)
+	}
+}
+
+// push adds values vs to the back of the stack, applying the stack's
+// OverflowPolicy if it's bounded and there's no room for them.
+func (s *BlockingStack[T]) push(ctx context.Context, vs []T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	size := s.sizeOf(vs...)
+	vs, size, err := s.makeRoom(ctx, vs, size)
+	if err != nil || len(vs) == 0 {
+		return err
+	}
+
+	if len(vs) == 1 {
+		s.s.Push(vs[0])
+	} else {
+		s.s.PushAll(vs...)
+	}
+	s.bytes += size
+	for i := 0; i < len(vs) && s.poppers.signal(); i++ {
+	}
+	if s.policy == Block && !s.exceeds(s.s.Len()+1, s.bytes) {
+		// There may be room left for the next producer waiting; it's woken
+		// up in turn, so the wakeup goes down the queue while room suffices.
+		s.pushers.signal()
+	}
+	s.setReady()
+	return nil
+}
+
+// popped updates the stack state after value v was popped: it drops the
+// NotEmpty channel if the stack got empty, so NotEmpty creates a new one, and
+// wakes up the first producer waiting for room, if any.
+// s.mu must be held when calling popped.
+func (s *BlockingStack[T]) popped(v T) {
+	s.bytes -= s.sizeOf(v)
+	if s.s.Len() == 0 && !s.closed {
+		s.ready = nil
+	}
+	s.pushers.signal()
+}
+
+// wait blocks until waiter w of q is signaled or ctx is done, returning
+// ctx.Err() in the latter case. s.mu must be held when calling wait; it's released while
+// waiting and held again when wait returns.
+// Callers must check their condition again after wait returns, as another
+// goroutine may have changed the stack before s.mu was locked again.
+func (s *BlockingStack[T]) wait(ctx context.Context, q *waitq, w chan struct{}) error {
+	s.mu.Unlock()
+	select {
+	case <-w:
+		s.mu.Lock()
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		if !q.remove(w) {
+			// w was signaled while giving up; pass the signal on to the next
+			// waiter so the change that triggered it doesn't go unnoticed.
+			q.signal()
+		}
+		return ctx.Err()
+	}
+}
+
+// add adds a new waiter to the back of the queue and returns the channel it
+// should wait on.
+func (q *waitq) add() chan struct{} {
+	w := make(chan struct{}, 1)
+	q.ws = append(q.ws, w)
+	return w
+}
+
+// addFront adds a new waiter to the front of the queue and returns the channel
+// it should wait on. It's used by waiters that were woken up too early, so they
+// wait again without losing their place.
+func (q *waitq) addFront() chan struct{} {
+	w := make(chan struct{}, 1)
+	q.ws = slices.Insert(q.ws, 0, w)
+	return w
+}
+
+// remove removes waiter w from the queue, reporting whether it was waiting.
+func (q *waitq) remove(w chan struct{}) bool {
+	for i, x := range q.ws {
+		if x == w {
+			q.ws = slices.Delete(q.ws, i, i+1)
+			return true
+		}
+	}
+	return false
+}
+
+// signal wakes up the waiter at the front of the queue, removing it from the
+// queue, and reports whether there was a waiter to wake up.
+func (q *waitq) signal() bool {
+	if len(q.ws) == 0 {
+		return false
+	}
+	q.ws[0] <- struct{}{}
+	q.ws[0] = nil // Avoid memory leaks
+	q.ws = q.ws[1:]
+	return true
+}
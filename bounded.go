@@ -0,0 +1,180 @@
+// Copyright (c) 2018 ef-ds
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package stack
+
+import (
+	"context"
+	"errors"
+)
+
+// OverflowPolicy defines what a bounded stack does with values pushed to it
+// when it's full.
+type OverflowPolicy int
+
+const (
+	// Reject rejects the values, which makes Push return ErrFull.
+	Reject OverflowPolicy = iota
+
+	// DropBottom makes room for the values by dropping the oldest values in
+	// the stack, at its bottom. The dropped values are counted by Dropped.
+	DropBottom
+
+	// Block blocks Push until consumers pop enough values to make room for
+	// the values, or until the stack is closed. Blocked producers get room
+	// in the order they started waiting.
+	Block
+)
+
+// ErrFull is returned when pushing to a full bounded stack with the Reject
+// policy, when pushing more values at once than a bounded stack can hold with
+// the Block policy, or when none of the values pushed fit in a sized stack's
+// budget with the DropBottom policy.
+var ErrFull = errors.New("stack: stack full")
+
+// NewBounded returns an initialized blocking stack that holds up to max
+// values, applying policy to the values pushed when it's full.
+// NewBounded panics if max is less than 1.
+func NewBounded[T any](max int, policy OverflowPolicy) *BlockingStack[T] {
+	if max < 1 {
+		panic("stack: bound must be positive")
+	}
+	return &BlockingStack[T]{max: max, policy: policy}
+}
+
+// Sizer returns the size of value v, such as its size in bytes.
+type Sizer[T any] func(v T) int
+
+// NewSized returns an initialized blocking stack that holds values up to a
+// total size of budget, as measured by sizer, applying policy to the values
+// pushed when there's no room for them. This caps the memory used by the
+// stack more accurately than a maximum number of values when the values vary
+// in size, such as byte slices or strings.
+// sizer must return a non-negative size, and the same size for a value every
+// time it's called. It's called with the stack locked, so it should be fast.
+// NewSized panics if budget is less than 1 or sizer is nil.
+func NewSized[T any](budget int, sizer Sizer[T], policy OverflowPolicy) *BlockingStack[T] {
+	if budget < 1 {
+		panic("stack: budget must be positive")
+	}
+	if sizer == nil {
+		panic("stack: nil sizer")
+	}
+	return &BlockingStack[T]{budget: budget, sizer: sizer, policy: policy}
+}
+
+// Max returns the maximum number of values the stack holds, or 0 if the
+// stack is unbounded.
+// The complexity is O(1).
+func (s *BlockingStack[T]) Max() int {
+	return s.max // Immutable
+}
+
+// Dropped returns the number of values dropped by the DropBottom policy so
+// far, which includes the values dropped from the bottom of the stack and the
+// values of a single PushAll call that didn't fit in the stack.
+// The complexity is O(1).
+func (s *BlockingStack[T]) Dropped() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// Bytes returns the total size of the values in the stack, as measured by
+// the sizer of a stack created by NewSized, or 0 for other stacks.
+// The complexity is O(1).
+func (s *BlockingStack[T]) Bytes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bytes
+}
+
+// makeRoom makes room for values vs, whose total size is size, according to
+// the stack's policy. It returns the values that should be pushed, which may
+// be only the last ones of vs under the DropBottom policy, and their size.
+// s.mu must be held when calling makeRoom.
+func (s *BlockingStack[T]) makeRoom(ctx context.Context, vs []T, size int) ([]T, int, error) {
+	// Under the Block policy, producers get room in the order they started
+	// waiting: a new producer waits behind the ones already waiting, and a
+	// producer woken up before there's enough room waits again at the front.
+	woken := false
+	for s.exceeds(s.s.Len()+len(vs), s.bytes+size) ||
+		(s.policy == Block && !woken && len(s.pushers.ws) > 0) {
+		switch s.policy {
+		case DropBottom:
+			// The first values pushed would be dropped right away. If none
+			// of them fit, even in an empty stack, they're rejected instead.
+			k, n := 0, size
+			for k < len(vs) && s.exceeds(len(vs)-k, n) {
+				n -= s.sizeOf(vs[k])
+				k++
+			}
+			if k == len(vs) {
+				return nil, 0, ErrFull
+			}
+			vs, size = vs[k:], n
+			s.dropped += k
+			for s.exceeds(s.s.Len()+len(vs), s.bytes+size) {
+				v, _ := s.s.popBottom()
+				s.bytes -= s.sizeOf(v)
+				s.dropped++
+			}
+		case Block:
+			if s.exceeds(len(vs), size) {
+				return nil, 0, ErrFull
+			}
+			var w chan struct{}
+			if woken {
+				w = s.pushers.addFront()
+			} else {
+				w = s.pushers.add()
+			}
+			if err := s.wait(ctx, &s.pushers, w); err != nil {
+				return nil, 0, err
+			}
+			woken = true
+			if s.closed {
+				return nil, 0, ErrClosed
+			}
+		default:
+			return nil, 0, ErrFull
+		}
+	}
+	return vs, size, nil
+}
+
+// exceeds reports whether n values whose total size is size exceed the
+// stack's bounds.
+func (s *BlockingStack[T]) exceeds(n, size int) bool {
+	return (s.max > 0 && n > s.max) || (s.budget > 0 && size > s.budget)
+}
+
+// sizeOf returns the total size of values vs, as measured by the stack's
+// sizer, or 0 if it has none.
+func (s *BlockingStack[T]) sizeOf(vs ...T) int {
+	if s.sizer == nil {
+		return 0
+	}
+	size := 0
+	for _, v := range vs {
+		size += s.sizer(v)
+	}
+	return size
+}
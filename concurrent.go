@@ -0,0 +1,176 @@
+// Copyright (c) 2018 ef-ds
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package stack
+
+import (
+	"iter"
+	"sync/atomic"
+)
+
+// ConcurrentStack implements an unbounded, lock-free Last-In-First-Out (LIFO)
+// stack data structure that is safe for concurrent use by multiple goroutines.
+// The zero value for ConcurrentStack is an empty stack ready to use.
+//
+// ConcurrentStack is a Treiber stack: the stack is a linked list of immutable
+// nodes and all operations change it by swapping its top node with a single
+// compare-and-swap. Nodes are never reused; each change links a newly
+// allocated node, which the garbage collector guarantees can't have the same
+// address as any node still referenced by another goroutine. That protects the
+// stack from the ABA problem without tagged pointers or hazard pointers.
+//
+// Each node holds a single value, so Pop only needs to swap the top node with
+// the previous one and never allocates. PushAll allocates the nodes for all
+// its values at once, as a single chunk, and links them to the stack with a
+// single compare-and-swap. As the chunk is a single allocation, values popped
+// from it are only released once the whole chunk is popped.
+type ConcurrentStack[T any] struct {
+	// top points to the top node of the stack, or nil if the stack is empty.
+	top atomic.Pointer[cnode[T]]
+}
+
+// cnode represents a concurrent stack node.
+// Nodes are never changed once they are linked to the stack.
+type cnode[T any] struct {
+	// v holds the value of this node.
+	v T
+
+	// p points to the previous node in the linked list.
+	p *cnode[T]
+
+	// len holds the length of the stack up to and including this node.
+	len int
+}
+
+// NewConcurrent returns an initialized, lock-free stack.
+func NewConcurrent[T any]() *ConcurrentStack[T] {
+	return new(ConcurrentStack[T])
+}
+
+// Len returns the number of elements of stack s.
+// The complexity is O(1).
+func (s *ConcurrentStack[T]) Len() int {
+	if t := s.top.Load(); t != nil {
+		return t.len
+	}
+	return 0
+}
+
+// Back returns the last element of stack s or the zero value of T if the stack is empty.
+// The second, bool result indicates whether a valid value was returned;
+// if the stack is empty, false will be returned.
+// The complexity is O(1).
+func (s *ConcurrentStack[T]) Back() (T, bool) {
+	t := s.top.Load()
+	if t == nil {
+		var zero T
+		return zero, false
+	}
+	return t.v, true
+}
+
+// Push adds value v to the the back of the stack.
+// The complexity is O(1).
+func (s *ConcurrentStack[T]) Push(v T) {
+	ns := []cnode[T]{{v: v}}
+	for !s.tryPush(ns) {
+	}
+}
+
+// PushAll adds values vs to the back of the stack, in order, so the last
+// value in vs becomes the last element of the stack. The values are pushed
+// atomically, as a single chunk of nodes allocated at once.
+// The complexity is O(len(vs)).
+func (s *ConcurrentStack[T]) PushAll(vs ...T) {
+	if len(vs) == 0 {
+		return
+	}
+	ns := make([]cnode[T], len(vs))
+	for i, v := range vs {
+		ns[i].v = v
+		if i > 0 {
+			ns[i].p = &ns[i-1]
+		}
+	}
+	for !s.tryPush(ns) {
+	}
+}
+
+// Pop retrieves and removes the current element from the back of the stack.
+// The second, bool result indicates whether a valid value was returned;
+// if the stack is empty, false will be returned.
+// The complexity is O(1).
+func (s *ConcurrentStack[T]) Pop() (T, bool) {
+	for {
+		if v, ok, done := s.tryPop(); done {
+			return v, ok
+		}
+	}
+}
+
+// All returns an iterator over the stack values, from the top (back) of the
+// stack down to its bottom. The index yielded with each value is its depth:
+// 0 for the top value, Len()-1 for the bottom one.
+// As nodes are immutable, the iterator walks a consistent snapshot of the
+// stack taken when the iteration starts, without blocking other goroutines.
+func (s *ConcurrentStack[T]) All() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		i := 0
+		for n := s.top.Load(); n != nil; n = n.p {
+			if !yield(i, n.v) {
+				return
+			}
+			i++
+		}
+	}
+}
+
+// tryPush links nodes ns, each one linked to the one before it, on top of the
+// stack with a single compare-and-swap and reports whether it succeeded.
+func (s *ConcurrentStack[T]) tryPush(ns []cnode[T]) bool {
+	t := s.top.Load()
+	ns[0].p = t
+	l := 0
+	if t != nil {
+		l = t.len
+	}
+	for i := range ns {
+		l++
+		ns[i].len = l
+	}
+	return s.top.CompareAndSwap(t, &ns[len(ns)-1])
+}
+
+// tryPop tries to remove the current element from the back of the stack with
+// a single compare-and-swap. The third, bool result reports whether the
+// attempt completed, either by popping a value or by finding the stack empty,
+// as opposed to losing the race with another goroutine.
+func (s *ConcurrentStack[T]) tryPop() (T, bool, bool) {
+	var zero T
+	t := s.top.Load()
+	if t == nil {
+		return zero, false, true
+	}
+
+	if !s.top.CompareAndSwap(t, t.p) {
+		return zero, false, false
+	}
+	return t.v, true, true
+}
@@ -3,11 +3,11 @@ package stack_test
 import (
 	"fmt"
 
-	"github.com/ef-ds/stack"
+	"github.com/ef-ds/stack/v2"
 )
 
 func Example() {
-	var s stack.Stack
+	var s stack.Stack[int]
 
 	for i := 1; i <= 5; i++ {
 		s.Push(i)
@@ -18,3 +18,29 @@ func Example() {
 	}
 	// Output: 54321
 }
+
+func ExampleStack_All() {
+	var s stack.Stack[string]
+	s.Push("a")
+	s.Push("b")
+	s.Push("c")
+
+	for i, v := range s.All() {
+		fmt.Print(i, v, " ")
+	}
+	fmt.Println(s.Len())
+	// Output: 0c 1b 2a 3
+}
+
+func ExampleStack_Drain() {
+	var s stack.Stack[int]
+
+	for i := 1; i <= 5; i++ {
+		s.Push(i)
+	}
+	for v := range s.Drain() {
+		fmt.Print(v)
+	}
+	fmt.Println(s.Len())
+	// Output: 543210
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2018 ef-ds
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package stack
+
+import (
+	"iter"
+	"math/rand/v2"
+	"runtime"
+	"sync/atomic"
+)
+
+const (
+	// eliminationSlots holds the number of slots in the elimination array.
+	eliminationSlots = 16
+
+	// eliminationSpins holds the number of times a goroutine checks its
+	// elimination slot for a match before giving up.
+	eliminationSpins = 32
+
+	// cacheLineSize holds the assumed CPU cache line size, used to keep
+	// elimination slots from sharing cache lines.
+	cacheLineSize = 64
+)
+
+// EliminationStack implements an unbounded, lock-free Last-In-First-Out (LIFO)
+// stack data structure that is safe for concurrent use by multiple goroutines.
+// The zero value for EliminationStack is an empty stack ready to use.
+//
+// EliminationStack is a ConcurrentStack with an elimination-backoff layer.
+// When a Push or Pop loses the race to change the top of the stack, instead
+// of retrying right away, it backs off to a randomly chosen slot of an
+// elimination array, where it waits briefly for an operation of the opposite
+// kind. A Push and a Pop that meet there cancel each other out: the value is
+// handed from one goroutine to the other without ever touching the top of the
+// stack. Under heavy contention, that turns the compare-and-swap storms on the
+// top of the stack into pairs of operations that complete in parallel.
+type EliminationStack[T any] struct {
+	// s holds the stack values.
+	s ConcurrentStack[T]
+
+	// slots holds the elimination array.
+	slots [eliminationSlots]eslot[T]
+}
+
+// eslot represents an elimination array slot.
+type eslot[T any] struct {
+	// n points to the node offered by a Push waiting for a Pop, if any.
+	n atomic.Pointer[cnode[T]]
+
+	_ [cacheLineSize - 8]byte
+}
+
+// NewElimination returns an initialized, lock-free stack with elimination-backoff.
+func NewElimination[T any]() *EliminationStack[T] {
+	return new(EliminationStack[T])
+}
+
+// Len returns the number of elements of stack s.
+// Values being handed over through the elimination array are never counted.
+// The complexity is O(1).
+func (s *EliminationStack[T]) Len() int { return s.s.Len() }
+
+// Back returns the last element of stack s or the zero value of T if the stack is empty.
+// The second, bool result indicates whether a valid value was returned;
+// if the stack is empty, false will be returned.
+// The complexity is O(1).
+func (s *EliminationStack[T]) Back() (T, bool) { return s.s.Back() }
+
+// Push adds value v to the the back of the stack.
+// The complexity is O(1).
+func (s *EliminationStack[T]) Push(v T) {
+	ns := []cnode[T]{{v: v}}
+	for !s.s.tryPush(ns) && !s.exchangePush(&ns[0]) {
+	}
+}
+
+// PushAll adds values vs to the back of the stack, in order, so the last
+// value in vs becomes the last element of the stack. The values are pushed
+// atomically, as a single chunk of nodes, so they are never eliminated.
+// The complexity is O(len(vs)).
+func (s *EliminationStack[T]) PushAll(vs ...T) { s.s.PushAll(vs...) }
+
+// Pop retrieves and removes the current element from the back of the stack.
+// The second, bool result indicates whether a valid value was returned;
+// if the stack is empty, false will be returned.
+// The complexity is O(1).
+func (s *EliminationStack[T]) Pop() (T, bool) {
+	for {
+		if v, ok, done := s.s.tryPop(); done {
+			return v, ok
+		}
+		if v, ok := s.exchangePop(); ok {
+			return v, true
+		}
+	}
+}
+
+// All returns an iterator over the stack values, from top to bottom.
+// See ConcurrentStack.All.
+func (s *EliminationStack[T]) All() iter.Seq2[int, T] { return s.s.All() }
+
+// exchangePush offers node n in a random elimination slot and waits briefly
+// for a Pop to take it. It reports whether n was taken.
+func (s *EliminationStack[T]) exchangePush(n *cnode[T]) bool {
+	slot := &s.slots[rand.IntN(eliminationSlots)].n
+	if !slot.CompareAndSwap(nil, n) {
+		return false // Slot busy; back to the stack.
+	}
+	for i := 0; i < eliminationSpins; i++ {
+		if slot.Load() != n {
+			return true
+		}
+		runtime.Gosched()
+	}
+
+	// Withdraw the offer, unless a Pop took it in the meantime.
+	return !slot.CompareAndSwap(n, nil)
+}
+
+// exchangePop waits briefly in a random elimination slot for a node offered by
+// a Push and takes its value. The second, bool result reports whether a value
+// was taken.
+func (s *EliminationStack[T]) exchangePop() (T, bool) {
+	slot := &s.slots[rand.IntN(eliminationSlots)].n
+	for i := 0; i < eliminationSpins; i++ {
+		if n := slot.Load(); n != nil && slot.CompareAndSwap(n, nil) {
+			return n.v, true
+		}
+		runtime.Gosched()
+	}
+	var zero T
+	return zero, false
+}
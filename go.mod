@@ -1,3 +1,3 @@
-module github.com/ef-ds/stack
+module github.com/ef-ds/stack/v2
 
-go 1.11
+go 1.23
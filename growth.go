@@ -0,0 +1,149 @@
+// Copyright (c) 2018 ef-ds
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package stack
+
+import (
+	"math/bits"
+	"sync/atomic"
+)
+
+// GrowthPolicy decides the size of the nodes of a stack as it grows.
+// Set it with WithGrowthPolicy.
+//
+// Unlike the default node sizing, where the first node is reallocated as it
+// doubles in size, a stack with a growth policy never reallocates its nodes:
+// it links a new node, sized by the policy, each time it runs out of room.
+// Indexing values with At, Get and Set is then O(log k) instead of O(1),
+// where k is the number of nodes in the stack.
+type GrowthPolicy interface {
+	// NodeSize returns the size of the next node of a stack holding n values,
+	// whose last node has size last, or 0 if it's the stack's first node.
+	// Sizes less than 1 are treated as 1.
+	// A policy shared by multiple stacks must be safe for concurrent use.
+	NodeSize(n, last int) int
+}
+
+// observer is implemented by growth policies that learn from the length of
+// the stacks using them, reported when the stacks are cleared.
+type observer interface {
+	observe(n int)
+}
+
+// GeometricGrowth is a GrowthPolicy whose nodes double in size, from First
+// up to Max. Zero First and Max default to 8 and 512 values, the default
+// node sizes, but a larger Max lets huge stacks use fewer, larger nodes.
+type GeometricGrowth struct {
+	// First holds the size of the first node.
+	First int
+
+	// Max holds the maximum size of the nodes.
+	Max int
+}
+
+// NodeSize returns the size of the next node. See GrowthPolicy.
+func (g GeometricGrowth) NodeSize(n, last int) int {
+	first, max := g.bounds()
+	if last == 0 {
+		return first
+	}
+	return clampSize(last*2, first, max)
+}
+
+// bounds returns the first and maximum node sizes, applying the defaults.
+func (g GeometricGrowth) bounds() (int, int) {
+	first, max := g.First, g.Max
+	if first <= 0 {
+		first = firstSliceSize
+	}
+	if max <= 0 {
+		max = maxInternalSliceSize
+	}
+	return min(first, max), max
+}
+
+// FixedGrowth is a GrowthPolicy whose nodes, the first one included, all have
+// the same size. Zero Size defaults to 512 values.
+type FixedGrowth struct {
+	// Size holds the size of the nodes.
+	Size int
+}
+
+// NodeSize returns the size of the next node. See GrowthPolicy.
+func (f FixedGrowth) NodeSize(n, last int) int {
+	if f.Size <= 0 {
+		return maxInternalSliceSize
+	}
+	return f.Size
+}
+
+// AdaptiveGrowth is a GrowthPolicy that learns from the stacks using it.
+// It keeps track of their recent high-water mark, the largest number of
+// values they held, and sizes the first node of new stacks, and of stacks
+// cleared by Init, to hold that many values, so stacks that are created
+// often, such as once per request, rarely need a second node. Further nodes
+// double in size, like GeometricGrowth.
+// Stacks report their length when Init, Reset or Shrink clears them, while
+// a growing stack counts as filling half of the node it grows.
+// The high-water mark decays as new stacks are created, so the policy adapts
+// when the stacks get smaller. All node sizes are kept between Min and Max,
+// which default to 8 and 512 values.
+//
+// AdaptiveGrowth is safe for concurrent use, so it can be shared by stacks
+// used by different goroutines. It must not be copied after first use.
+type AdaptiveGrowth struct {
+	// Min holds the minimum size of the nodes.
+	Min int
+
+	// Max holds the maximum size of the nodes.
+	Max int
+
+	// mark holds the recent high-water mark.
+	mark atomic.Int64
+}
+
+// NodeSize returns the size of the next node. See GrowthPolicy.
+func (a *AdaptiveGrowth) NodeSize(n, last int) int {
+	first, max := GeometricGrowth{First: a.Min, Max: a.Max}.bounds()
+	if last == 0 {
+		m := a.mark.Load()
+		a.mark.CompareAndSwap(m, m-m/4) // Losing the race just skips a decay.
+		size := 1
+		if m > 1 {
+			size = 1 << bits.Len64(uint64(m-1)) // The next power of two.
+		}
+		return clampSize(size, first, max)
+	}
+	// The stack holds more than n values, but how many more is unknown until
+	// it's cleared, so count half of the next node, twice the size of the last.
+	a.observe(n + last)
+	return clampSize(last*2, first, max)
+}
+
+// observe raises the high-water mark to n values.
+func (a *AdaptiveGrowth) observe(n int) {
+	for m := a.mark.Load(); int64(n) > m && !a.mark.CompareAndSwap(m, int64(n)); m = a.mark.Load() {
+	}
+}
+
+// clampSize returns size limited to the range [lo, hi].
+func clampSize(size, lo, hi int) int {
+	return min(max(size, lo), hi)
+}
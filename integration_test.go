@@ -23,7 +23,7 @@ package stack_test
 import (
 	"testing"
 
-	"github.com/ef-ds/stack"
+	"github.com/ef-ds/stack/v2"
 )
 
 const (
@@ -31,14 +31,14 @@ const (
 )
 
 func TestFillStackShouldRetrieveAllElementsInOrder(t *testing.T) {
-	var s stack.Stack
+	var s stack.Stack[int]
 
 	for i := 0; i < pushCount; i++ {
 		s.Push(i)
 	}
 	//fmt.Println(spew.Sdump(d))
 	for i := pushCount - 1; i >= 0; i-- {
-		if v, ok := s.Pop(); !ok || v.(int) != i {
+		if v, ok := s.Pop(); !ok || v != i {
 			t.Errorf("Expected: %d; Got: %d", i, v)
 		}
 	}
@@ -48,14 +48,14 @@ func TestFillStackShouldRetrieveAllElementsInOrder(t *testing.T) {
 }
 
 func TestRefillStackShouldRetrieveAllElementsInOrder(t *testing.T) {
-	var s stack.Stack
+	var s stack.Stack[int]
 
 	for i := 0; i < refillCount; i++ {
 		for j := 0; j < pushCount; j++ {
 			s.Push(j)
 		}
 		for j := pushCount - 1; j >= 0; j-- {
-			if v, ok := s.Pop(); !ok || v.(int) != j {
+			if v, ok := s.Pop(); !ok || v != j {
 				t.Errorf("Expected: %d; Got: %d", i, v)
 			}
 		}
@@ -66,7 +66,7 @@ func TestRefillStackShouldRetrieveAllElementsInOrder(t *testing.T) {
 }
 
 func TestRefillFullStackShouldRetrieveAllElementsInOrder(t *testing.T) {
-	var s stack.Stack
+	var s stack.Stack[int]
 	for i := 0; i < pushCount; i++ {
 		s.Push(i)
 	}
@@ -76,7 +76,7 @@ func TestRefillFullStackShouldRetrieveAllElementsInOrder(t *testing.T) {
 			s.Push(j)
 		}
 		for j := pushCount - 1; j >= 0; j-- {
-			if v, ok := s.Pop(); !ok || v.(int) != j {
+			if v, ok := s.Pop(); !ok || v != j {
 				t.Errorf("Expected: %d; Got: %d", j, v)
 			}
 		}
@@ -87,7 +87,7 @@ func TestRefillFullStackShouldRetrieveAllElementsInOrder(t *testing.T) {
 }
 
 func TestSlowIncreaseStackShouldRetrieveAllElementsInOrder(t *testing.T) {
-	var s stack.Stack
+	var s stack.Stack[int]
 
 	count := 0
 	for i := 0; i < pushCount; i++ {
@@ -95,7 +95,7 @@ func TestSlowIncreaseStackShouldRetrieveAllElementsInOrder(t *testing.T) {
 		s.Push(count)
 		count++
 		s.Push(count)
-		if v, ok := s.Pop(); !ok || v.(int) != count {
+		if v, ok := s.Pop(); !ok || v != count {
 			t.Errorf("Expected: %d; Got: %d", count, v)
 		}
 	}
@@ -105,7 +105,7 @@ func TestSlowIncreaseStackShouldRetrieveAllElementsInOrder(t *testing.T) {
 }
 
 func TestSlowDecreaseStackShouldRetrieveAllElementsInOrder(t *testing.T) {
-	var s stack.Stack
+	var s stack.Stack[int]
 	push := 0
 	for i := 0; i < pushCount; i++ {
 		s.Push(push)
@@ -115,11 +115,11 @@ func TestSlowDecreaseStackShouldRetrieveAllElementsInOrder(t *testing.T) {
 	count := push
 	for i := 0; i < pushCount-1; i++ {
 		count--
-		if v, ok := s.Pop(); !ok || v.(int) != count {
+		if v, ok := s.Pop(); !ok || v != count {
 			t.Errorf("Expected: %d; Got: %d", count, v)
 		}
 		count--
-		if v, ok := s.Pop(); !ok || v.(int) != count {
+		if v, ok := s.Pop(); !ok || v != count {
 			t.Errorf("Expected: %d; Got: %d", count, v)
 		}
 
@@ -127,7 +127,7 @@ func TestSlowDecreaseStackShouldRetrieveAllElementsInOrder(t *testing.T) {
 		count++
 	}
 	count--
-	if v, ok := s.Pop(); !ok || v.(int) != count {
+	if v, ok := s.Pop(); !ok || v != count {
 		t.Errorf("Expected: %d; Got: %d", count, v)
 	}
 	if s.Len() != 0 {
@@ -136,11 +136,11 @@ func TestSlowDecreaseStackShouldRetrieveAllElementsInOrder(t *testing.T) {
 }
 
 func TestStableStackShouldRetrieveAllElementsInOrder(t *testing.T) {
-	var s stack.Stack
+	var s stack.Stack[int]
 
 	for i := 0; i < pushCount; i++ {
 		s.Push(i)
-		if v, ok := s.Pop(); !ok || v.(int) != i {
+		if v, ok := s.Pop(); !ok || v != i {
 			t.Errorf("Expected: %d; Got: %d", i, v)
 		}
 	}
@@ -150,7 +150,7 @@ func TestStableStackShouldRetrieveAllElementsInOrder(t *testing.T) {
 }
 
 func TestStableFullStackShouldRetrieveAllElementsInOrder(t *testing.T) {
-	var s stack.Stack
+	var s stack.Stack[int]
 	for i := 0; i < pushCount; i++ {
 		s.Push(i)
 	}
@@ -158,7 +158,7 @@ func TestStableFullStackShouldRetrieveAllElementsInOrder(t *testing.T) {
 	count := 0
 	for i := 0; i < pushCount; i++ {
 		s.Push(i)
-		if v, ok := s.Pop(); !ok || v.(int) != count {
+		if v, ok := s.Pop(); !ok || v != count {
 			t.Errorf("Expected: %d; Got: %d", count, v)
 		}
 		count++
@@ -169,7 +169,7 @@ func TestStableFullStackShouldRetrieveAllElementsInOrder(t *testing.T) {
 }
 
 func TestPushFrontPopRefillWith0ToPushCountItemsShouldReturnAllValuesInOrder(t *testing.T) {
-	var s stack.Stack
+	var s stack.Stack[int]
 
 	for i := 0; i < refillCount; i++ {
 		for k := 0; k < pushCount; k++ {
@@ -178,7 +178,7 @@ func TestPushFrontPopRefillWith0ToPushCountItemsShouldReturnAllValuesInOrder(t *
 			}
 			for j := k; j > 0; j-- {
 				v, ok := s.Pop()
-				if !ok || v == nil || v.(int) != j-1 {
+				if !ok || v != j-1 {
 					t.Errorf("Expected: %d; Got: %d", j-1, v)
 				}
 			}
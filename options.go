@@ -0,0 +1,189 @@
+// Copyright (c) 2018 ef-ds
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package stack
+
+// Option configures a stack created by NewWithOptions.
+type Option func(*config)
+
+// config holds the settings NewWithOptions applies to a stack.
+type config struct {
+	options
+
+	// pool holds the *Pool[T] set by WithPool, if any.
+	pool any
+}
+
+// settings holds the settings of a stack. Stacks using the defaults have no
+// settings, which keeps them small.
+type settings[T any] struct {
+	options
+
+	// pool holds the pool nodes are taken from and returned to, if any.
+	pool *Pool[T]
+}
+
+// defaults holds the default options.
+var defaults options
+
+// opts returns the options of stack s.
+func (s *Stack[T]) opts() *options {
+	if s.cfg == nil {
+		return &defaults
+	}
+	return &s.cfg.options
+}
+
+// pool returns the pool of stack s, if any.
+func (s *Stack[T]) pool() *Pool[T] {
+	if s.cfg == nil {
+		return nil
+	}
+	return s.cfg.pool
+}
+
+// options holds the settings of a stack. The zero value holds the defaults.
+type options struct {
+	// firstNodeSize holds the initial size of the first node, or 0 for
+	// firstSliceSize.
+	firstNodeSize int
+
+	// nodeSize holds the size of the nodes, which the first node grows up to,
+	// or 0 for maxInternalSliceSize.
+	nodeSize int
+
+	// spareNodes holds the maximum number of spare nodes to keep, 0 for
+	// defaultSpareNodes, or a negative value for none.
+	spareNodes int
+
+	// growth holds the policy that sizes the nodes, if any.
+	growth GrowthPolicy
+}
+
+// NewWithOptions returns an initialized stack configured by opts.
+// Stacks created by New, or declared as zero values, use the defaults: a
+// first node of 8 values that doubles in size as needed up to 512 values,
+// further nodes of 512 values, and one spare node.
+// NewWithOptions panics if an option doesn't apply to stacks of type T, such
+// as WithPool with a pool of a different type.
+func NewWithOptions[T any](opts ...Option) *Stack[T] {
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	s := new(Stack[T])
+	if len(opts) == 0 {
+		return s
+	}
+
+	s.cfg = &settings[T]{options: c.options}
+	if c.pool != nil {
+		p, ok := c.pool.(*Pool[T])
+		if !ok {
+			panic("stack: pool of a different type")
+		}
+		s.cfg.pool = p
+	}
+	if s.cfg.firstNodeSize > s.cfg.nodeCap() {
+		s.cfg.firstNodeSize = s.cfg.nodeCap()
+	}
+	return s
+}
+
+// WithFirstNodeSize sets the initial size of the first node, n >= 1.
+// The first node doubles in size as values are pushed, up to the node size.
+// Stacks that hold few values, such as up to 3, use less memory with a small
+// first node, while stacks that quickly grow large avoid resizing it with a
+// first node as large as the node size.
+// WithFirstNodeSize panics if n is less than 1.
+func WithFirstNodeSize(n int) Option {
+	if n < 1 {
+		panic("stack: first node size must be positive")
+	}
+	return func(c *config) { c.firstNodeSize = n }
+}
+
+// WithNodeSize sets the size of the nodes, n >= 1. Stacks that hold many
+// values allocate fewer, larger nodes with a large node size, while a small
+// node size wastes less memory in the last node.
+// WithNodeSize panics if n is less than 1.
+func WithNodeSize(n int) Option {
+	if n < 1 {
+		panic("stack: node size must be positive")
+	}
+	return func(c *config) { c.nodeSize = n }
+}
+
+// WithSpareNodes sets the maximum number of spare nodes the stack keeps,
+// n >= 0. See Stack.SetSpareNodes.
+func WithSpareNodes(n int) Option {
+	return func(c *config) { c.setSpareNodes(n) }
+}
+
+// WithGrowthPolicy makes the stack size its nodes with policy p, which
+// replaces the node sizes set by WithFirstNodeSize and WithNodeSize.
+// See GrowthPolicy.
+func WithGrowthPolicy(p GrowthPolicy) Option {
+	return func(c *config) { c.growth = p }
+}
+
+// WithPool makes the stack take its nodes from pool p and return them to it.
+// p must be a pool of the same type as the stack. See Pool.
+func WithPool[T any](p *Pool[T]) Option {
+	return func(c *config) { c.pool = p }
+}
+
+// firstCap returns the initial capacity of the first node.
+func (o *options) firstCap() int {
+	if o.growth != nil {
+		return max(o.growth.NodeSize(0, 0), 1)
+	}
+	if o.firstNodeSize > 0 {
+		return o.firstNodeSize
+	}
+	return min(firstSliceSize, o.nodeCap())
+}
+
+// nodeCap returns the capacity of the nodes.
+func (o *options) nodeCap() int {
+	if o.nodeSize > 0 {
+		return o.nodeSize
+	}
+	return maxInternalSliceSize
+}
+
+// setSpareNodes sets the maximum number of spare nodes to keep, n >= 0.
+func (o *options) setSpareNodes(n int) {
+	if n <= 0 {
+		n = -1
+	}
+	o.spareNodes = n
+}
+
+// spareCap returns the maximum number of spare nodes to keep.
+func (o *options) spareCap() int {
+	switch {
+	case o.spareNodes > 0:
+		return o.spareNodes
+	case o.spareNodes < 0:
+		return 0
+	}
+	return defaultSpareNodes
+}
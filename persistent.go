@@ -0,0 +1,189 @@
+// Copyright (c) 2018 ef-ds
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package stack
+
+import (
+	"iter"
+	"slices"
+	"sync/atomic"
+)
+
+// PersistentStack implements an immutable Last-In-First-Out (LIFO) stack.
+// Push and Pop don't change the stack they're called on; they return a new
+// version of it instead, leaving all previous versions valid and unchanged.
+// The zero value for PersistentStack is an empty stack ready to use.
+//
+// Versions share their values through chunked, immutable nodes, so keeping
+// any number of versions around costs about as much as the values actually
+// pushed to them: a version branching off another one starts a small node of
+// its own, and nodes only grow as a branch keeps growing. As values are shared, popping a value doesn't release it;
+// it's only released once no version references it anymore.
+//
+// PersistentStack values are safe for concurrent use by multiple goroutines,
+// including pushing to the same version concurrently.
+type PersistentStack[T any] struct {
+	// c points to the top node of this version.
+	c *pnode[T]
+
+	// n holds the number of values in c that belong to this version.
+	n int
+
+	// len holds the number of values of this version.
+	len int
+}
+
+// pnode represents a persistent stack node.
+// Values in a node are never changed once written; versions pushing to the
+// same node claim the next free slot so only one of them can write to it.
+type pnode[T any] struct {
+	// v holds the node slots. Only slots claimed in used are written.
+	v []T
+
+	// used holds the number of claimed slots in v.
+	used atomic.Int32
+
+	// p points to the previous node, or nil if this is the first node.
+	p *pnode[T]
+
+	// pn holds the number of values in p that belong to the versions on
+	// top of this node.
+	pn int
+}
+
+// Len returns the number of elements of stack ps.
+// The complexity is O(1).
+func (ps PersistentStack[T]) Len() int { return ps.len }
+
+// Back returns the last element of stack ps or the zero value of T if the stack is empty.
+// The second, bool result indicates whether a valid value was returned;
+// if the stack is empty, false will be returned.
+// The complexity is O(1).
+func (ps PersistentStack[T]) Back() (T, bool) {
+	if ps.len == 0 {
+		var zero T
+		return zero, false
+	}
+	return ps.c.v[ps.n-1], true
+}
+
+// At returns the element at depth i of stack ps, where depth 0 is the last
+// element (the one Back returns) and depth Len()-1 the first one.
+// The second, bool result indicates whether a valid value was returned;
+// if i is out of range, false will be returned.
+// The complexity is O(i/maxInternalSliceSize).
+func (ps PersistentStack[T]) At(i int) (T, bool) {
+	if i < 0 || i >= ps.len {
+		var zero T
+		return zero, false
+	}
+
+	c, n := ps.c, ps.n
+	for i >= n {
+		i -= n
+		c, n = c.p, c.pn
+	}
+	return c.v[n-1-i], true
+}
+
+// Push returns a new version of stack ps with value v added to its back.
+// ps itself is left unchanged.
+// The complexity is O(1).
+func (ps PersistentStack[T]) Push(v T) PersistentStack[T] {
+	c, n := ps.c, ps.n
+	if c == nil || n == len(c.v) || !c.used.CompareAndSwap(int32(n), int32(n+1)) {
+		// The node is full or another version already pushed on top of this
+		// one, so link a new node to it.
+		c = &pnode[T]{v: make([]T, pnodeSize(c, n)), p: c, pn: n}
+		c.used.Store(1)
+		n = 0
+	}
+	c.v[n] = v
+	return PersistentStack[T]{c: c, n: n + 1, len: ps.len + 1}
+}
+
+// Pop returns a new version of stack ps without its last element, along with
+// the removed element. ps itself is left unchanged.
+// The third, bool result indicates whether a valid value was returned;
+// if the stack is empty, false will be returned.
+// The complexity is O(1).
+func (ps PersistentStack[T]) Pop() (PersistentStack[T], T, bool) {
+	if ps.len == 0 {
+		var zero T
+		return ps, zero, false
+	}
+
+	v := ps.c.v[ps.n-1]
+	r := PersistentStack[T]{c: ps.c, n: ps.n - 1, len: ps.len - 1}
+	if r.n == 0 {
+		r.c, r.n = r.c.p, r.c.pn // Move to the previous node.
+	}
+	return r, v, true
+}
+
+// All returns an iterator over the values of stack ps, from the top (back)
+// of the stack down to its bottom. The index yielded with each value is its
+// depth: 0 for the top value, Len()-1 for the bottom one.
+func (ps PersistentStack[T]) All() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		i := 0
+		for c, n := ps.c, ps.n; c != nil; c, n = c.p, c.pn {
+			for j := n - 1; j >= 0; j-- {
+				if !yield(i, c.v[j]) {
+					return
+				}
+				i++
+			}
+		}
+	}
+}
+
+// ToSlice returns a new slice with the elements of stack ps, from its first
+// element to its last one, which is the order they were pushed.
+// The complexity is O(n).
+func (ps PersistentStack[T]) ToSlice() []T {
+	return ps.AppendTo(make([]T, 0, ps.len))
+}
+
+// AppendTo appends the elements of stack ps to dst, from its first element to
+// its last one, and returns the extended slice.
+// The complexity is O(n).
+func (ps PersistentStack[T]) AppendTo(dst []T) []T {
+	dst = slices.Grow(dst, ps.len)
+	dst = dst[:len(dst)+ps.len]
+	i := len(dst)
+	for c, n := ps.c, ps.n; c != nil; c, n = c.p, c.pn {
+		i -= n
+		copy(dst[i:], c.v[:n])
+	}
+	return dst
+}
+
+// pnodeSize returns the size of a new node linked to the first n values of
+// node p. Nodes double in size along a chain of full nodes, from
+// firstSliceSize up to maxInternalSliceSize, while a branch off a node that
+// isn't full starts over from firstSliceSize, so neither small stacks nor
+// the many versions branching off a large one overallocate.
+func pnodeSize[T any](p *pnode[T], n int) int {
+	if p == nil || n < len(p.v) {
+		return firstSliceSize
+	}
+	return min(2*len(p.v), maxInternalSliceSize)
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2018 ef-ds
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package stack
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Pool implements a pool of internal stack nodes, shared by the stacks
+// created by its New method. Stacks take their nodes from the pool as they
+// grow, and return them to the pool, cleared, as they shrink or are cleared
+// by Init, which cuts allocations and GC pressure when stacks are created
+// and discarded often, such as once per request.
+// Nodes are pooled by size, so stacks of different node sizes, or with
+// growth policies, can share a pool, each reusing the nodes of its own sizes.
+// Pool is safe for concurrent use by multiple goroutines, but the stacks
+// using it aren't.
+// The zero value for Pool is an empty pool ready to use.
+type Pool[T any] struct {
+	// pools holds a *sync.Pool of the pooled nodes of each size.
+	pools sync.Map
+
+	// hits counts the nodes taken from the pool.
+	hits atomic.Uint64
+
+	// misses counts the nodes the pool didn't have, so they were allocated.
+	misses atomic.Uint64
+}
+
+// PoolStats holds the statistics of a Pool.
+type PoolStats struct {
+	// Hits is the number of nodes stacks took from the pool.
+	Hits uint64
+
+	// Misses is the number of nodes stacks needed but the pool didn't have,
+	// so they were allocated instead.
+	Misses uint64
+}
+
+// NewPool returns an initialized pool.
+func NewPool[T any]() *Pool[T] {
+	return new(Pool[T])
+}
+
+// New returns an initialized stack that takes its nodes from pool p.
+// Use NewWithOptions and WithPool to configure the stack further.
+func (p *Pool[T]) New() *Stack[T] {
+	return &Stack[T]{cfg: &settings[T]{pool: p}}
+}
+
+// Stats returns the pool statistics.
+// The complexity is O(1).
+func (p *Pool[T]) Stats() PoolStats {
+	return PoolStats{Hits: p.hits.Load(), Misses: p.misses.Load()}
+}
+
+// get takes an empty node of the given size from the pool, or returns nil if
+// it has none.
+func (p *Pool[T]) get(size int) *node[T] {
+	var n *node[T]
+	if sp, ok := p.pools.Load(size); ok {
+		n, _ = sp.(*sync.Pool).Get().(*node[T])
+	}
+	if n == nil {
+		p.misses.Add(1)
+		return nil
+	}
+	p.hits.Add(1)
+	return n
+}
+
+// put clears node n and returns it to the pool. n must not be referenced by
+// any stack or snapshot.
+func (p *Pool[T]) put(n *node[T]) {
+	clear(n.v)
+	n.v = n.v[:0]
+	n.p = nil
+	sp, ok := p.pools.Load(cap(n.v))
+	if !ok {
+		sp, _ = p.pools.LoadOrStore(cap(n.v), new(sync.Pool))
+	}
+	sp.(*sync.Pool).Put(n)
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2018 ef-ds
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package stack
+
+import "iter"
+
+// Snapshot is a read-only, point-in-time view of a stack, taken with
+// Stack.Snapshot. The stack can keep being changed while the snapshot is in
+// use; the snapshot always reflects the state of the stack when it was taken.
+//
+// Snapshots are immutable: they can be read by multiple goroutines at once,
+// even while the goroutine that owns the stack keeps changing it.
+type Snapshot[T any] struct {
+	// s holds the stack state when the snapshot was taken.
+	// Its nodes are never changed, neither by s nor by the snapshot.
+	s Stack[T]
+}
+
+// Len returns the number of elements of the snapshot.
+// The complexity is O(1).
+func (sn *Snapshot[T]) Len() int { return sn.s.Len() }
+
+// Back returns the last element of the snapshot or the zero value of T if the snapshot is empty.
+// The second, bool result indicates whether a valid value was returned;
+// if the snapshot is empty, false will be returned.
+// The complexity is O(1).
+func (sn *Snapshot[T]) Back() (T, bool) { return sn.s.Back() }
+
+// At returns the element at depth i of the snapshot. See Stack.At.
+// The complexity is O(1).
+func (sn *Snapshot[T]) At(i int) (T, bool) { return sn.s.At(i) }
+
+// Get returns the element at index i of the snapshot. See Stack.Get.
+// The complexity is O(1).
+func (sn *Snapshot[T]) Get(i int) (T, bool) { return sn.s.Get(i) }
+
+// PeekN copies up to n elements from the back of the snapshot into dst.
+// See Stack.PeekN.
+// The complexity is O(n).
+func (sn *Snapshot[T]) PeekN(dst []T, n int) int { return sn.s.PeekN(dst, n) }
+
+// All returns an iterator over the snapshot values, from top to bottom.
+// See Stack.All.
+func (sn *Snapshot[T]) All() iter.Seq2[int, T] { return sn.s.All() }
+
+// Backward returns an iterator over the snapshot values, from bottom to top.
+// See Stack.Backward.
+func (sn *Snapshot[T]) Backward() iter.Seq2[int, T] { return sn.s.Backward() }
+
+// ToSlice returns a new slice with the elements of the snapshot, from its
+// first element to its last one.
+// The complexity is O(n).
+func (sn *Snapshot[T]) ToSlice() []T { return sn.s.ToSlice() }
+
+// AppendTo appends the elements of the snapshot to dst, from its first
+// element to its last one, and returns the extended slice.
+// The complexity is O(n).
+func (sn *Snapshot[T]) AppendTo(dst []T) []T { return sn.s.AppendTo(dst) }
+
+// Clone returns a new, mutable stack holding the elements of the snapshot.
+// The complexity is O(n).
+func (sn *Snapshot[T]) Clone() *Stack[T] { return sn.s.Clone() }
@@ -24,95 +24,937 @@
 // running in production environments.
 package stack
 
+import (
+	"iter"
+	"slices"
+	"sort"
+)
+
 const (
-	// firstSliceSize holds the size of the first slice.
+	// firstSliceSize holds the default size of the first slice.
 	firstSliceSize = 8
 
-	// maxInternalSliceSize holds the maximum size of each internal slice.
+	// maxInternalSliceSize holds the default maximum size of each internal slice.
 	maxInternalSliceSize = 512
+
+	// defaultSpareNodes holds the number of spare nodes kept by default.
+	defaultSpareNodes = 1
+
+	// errModified is the panic message of iterators over a modified stack.
+	errModified = "stack: stack modified during iteration"
 )
 
 // Stack implements an unbounded, dynamically growing Last-In-First-Out (LIFO)
 // stack data structure.
 // The zero value for stack is an empty stack ready to use.
-type Stack struct {
+//
+// Stack[any] (or Stack[interface{}]) behaves exactly like the untyped v1
+// stack and can be used as a drop-in replacement when migrating from v1.
+type Stack[T any] struct {
 	// Tail points to the last node of the linked list.
 	// In an empty stack, head and tail points to the same node.
-	tail *node
+	tail *node[T]
+
+	// Nodes holds the nodes in the linked list, from the first to the tail.
+	// All nodes but the tail are full, so it allows indexing values in O(1).
+	// The first node may be smaller than the other nodes, as values removed
+	// from the bottom of the stack are sliced off it.
+	nodes []*node[T]
 
 	// Len holds the current stack values length.
 	len int
+
+	// Top holds the number of values in the tail. Push and Pop only keep top
+	// up to date, leaving the length of the tail's slice and the directory
+	// behind, so they stay cheap; sync brings them back in line before any
+	// other operation uses them.
+	top int
+
+	// Lim holds the capacity of the tail while the stack owns it and nothing
+	// but Push and Pop changed it since Push last made room in it, or 0
+	// otherwise, so Push can tell whether v fits in the tail with a single check.
+	lim int
+
+	// Mod counts the modifications made to the stack.
+	// Iterators use it to detect the stack was modified while iterating.
+	mod uint
+
+	// Gen holds the stack's current generation. Nodes created in an older
+	// generation may be shared with snapshots and are copied before changing.
+	gen uint
+
+	// Shared indicates whether the nodes directory may be shared with
+	// snapshots, in which case it's copied before changing.
+	shared bool
+
+	// Spare holds empty nodes released by the stack, kept to be reused by
+	// the next nodes it needs instead of allocating new ones.
+	spare []*node[T]
+
+	// Cfg holds the stack settings, or nil if the stack uses the defaults.
+	cfg *settings[T]
 }
 
 // Node represents a stack node.
 // Each node holds a slice of user managed values.
-type node struct {
+type node[T any] struct {
 	// v holds the list of user added values in this node.
-	v []interface{}
+	v []T
 
 	// p points to the previous node in the linked list.
-	p *node
+	p *node[T]
+
+	// g holds the generation of the stack that created the node.
+	g uint
+
+	// i holds the index of the first value of the node in the stack, counting
+	// the values removed from the bottom of the stack, so values can be
+	// indexed when nodes have different sizes.
+	i int
 }
 
 // New returns an initialized stack.
-func New() *Stack {
-	return new(Stack)
+func New[T any]() *Stack[T] {
+	return new(Stack[T])
 }
 
-// Init initializes or clears stack s.
-func (s *Stack) Init() *Stack {
-	*s = Stack{}
+// FromSlice returns a stack holding values vs, so the first value in vs is the
+// first element of the stack and the last value in vs is the last element.
+// The values are copied straight into nodes sized to hold them.
+func FromSlice[T any](vs []T) *Stack[T] {
+	s := new(Stack[T])
+	s.PushAll(vs...)
 	return s
 }
 
+// Init initializes or clears stack s. Init keeps the settings of the stack,
+// such as its number of spare nodes and its pool, but releases its nodes,
+// returning them to the pool, if any.
+func (s *Stack[T]) Init() *Stack[T] {
+	s.observe()
+	if s.pool() != nil {
+		s.recycle()
+	}
+	*s = Stack[T]{mod: s.mod + 1, cfg: s.cfg}
+	return s
+}
+
+// SetSpareNodes sets the maximum number of spare nodes stack s keeps, n >= 0.
+// When the stack shrinks enough to empty one of its internal nodes, the node
+// is kept as a spare node and reused when the stack grows again, so a stack
+// whose length oscillates around a node boundary doesn't allocate a new node
+// on every cycle. Spare nodes are only released once the stack keeps shrinking
+// and empties more nodes than it can keep.
+// By default, stacks keep one spare node.
+func (s *Stack[T]) SetSpareNodes(n int) {
+	c := new(settings[T])
+	if s.cfg != nil {
+		*c = *s.cfg // The settings may be shared with clones and snapshots.
+	}
+	c.setSpareNodes(n)
+	s.cfg = c
+	s.releaseSpare(c.spareCap())
+}
+
 // Len returns the number of elements of stack s.
 // The complexity is O(1).
-func (s *Stack) Len() int { return s.len }
+func (s *Stack[T]) Len() int { return s.len }
+
+// Reset removes all elements of stack s but, unlike Init, keeps its nodes to
+// hold new elements, so pushing them back doesn't allocate memory. The first
+// node is kept as is, while the other nodes are kept as spare nodes.
+// Nodes shared with snapshots can't be reused, so they're released instead.
+// The complexity is O(n).
+func (s *Stack[T]) Reset() {
+	if s.tail == nil {
+		return
+	}
+
+	s.observe()
+	s.sync()
+	s.ownNodes()
+	for k := len(s.nodes) - 1; k > 0; k-- {
+		// Spare nodes are taken from the top, so the nodes are reused in order.
+		if n := s.nodes[k]; n.g == s.gen && (s.opts().growth != nil || cap(n.v) == s.opts().nodeCap()) {
+			clear(n.v)
+			n.v, n.p = n.v[:0], nil
+			s.spare = append(s.spare, n)
+		}
+	}
+	f := s.nodes[0]
+	if f.g == s.gen {
+		clear(f.v)
+		f.v, f.i = f.v[:0], 0
+	} else {
+		f = &node[T]{v: make([]T, 0, cap(f.v)), g: s.gen}
+		f.p = f
+	}
+	clear(s.nodes[1:])
+	s.nodes = s.nodes[:1]
+	s.nodes[0] = f
+	s.tail = f
+	s.len = 0
+	s.top = 0
+	s.mod++
+}
+
+// Shrink releases the memory stack s doesn't need to hold its elements,
+// such as after a traffic spike drained: it releases its spare nodes, it
+// reallocates its first node if values were removed from its bottom, and it
+// reallocates its last node to the smallest size that fits its elements.
+// An empty stack releases all its nodes, just like Init.
+// The released nodes are returned to the stack's pool, if any, or otherwise
+// left for the garbage collector to return their memory to the runtime.
+// The complexity is O(m), where m is the node size.
+func (s *Stack[T]) Shrink() {
+	s.sync()
+	s.releaseSpare(0)
+	if s.tail == nil {
+		return
+	}
+	if s.len == 0 {
+		s.Init()
+		return
+	}
+
+	s.mod++
+	if f := s.nodes[0]; f != s.tail {
+		// With a growth policy, nodes have different sizes, so there's no
+		// telling whether values were removed from the first node.
+		if (s.opts().growth == nil && len(f.v) < s.opts().nodeCap()) || (s.opts().growth != nil && f.i > 0) {
+			s.resize(0, len(f.v))
+		}
+	}
+
+	l := len(s.tail.v)
+	c := max(l, 1)
+	if s.opts().growth == nil && s.opts().firstCap() < s.opts().nodeCap() {
+		// Keep the size the node would have grown to from the first node size,
+		// but never less than its length.
+		c = s.opts().firstCap()
+		for c < l {
+			c *= 2
+		}
+		c = max(min(c, s.opts().nodeCap()), l)
+	}
+	if c < cap(s.tail.v) {
+		n := s.tail
+		s.resize(len(s.nodes)-1, c)
+		if s.pool() != nil && n.g == s.gen && (s.opts().growth != nil || cap(n.v) == s.opts().nodeCap()) {
+			s.pool().put(n)
+		}
+	}
+}
+
+// Cap returns the capacity of stack s: the number of elements it can hold
+// before it needs to allocate memory, which counts the room left in its last
+// node and its spare nodes.
+// The complexity is O(k), where k is the number of spare nodes.
+func (s *Stack[T]) Cap() int {
+	s.sync()
+	c := s.len
+	if s.tail != nil {
+		c += cap(s.tail.v) - len(s.tail.v)
+	}
+	for _, n := range s.spare {
+		c += cap(n.v)
+	}
+	return c
+}
+
+// Reserve makes room for n more elements in stack s, n >= 0, allocating the
+// nodes needed to hold them upfront, so pushing up to n elements doesn't
+// allocate memory. The nodes are kept as spare nodes until they're needed.
+// Reserve is useful before a known burst of pushes, such as when decoding a
+// batch of records, on latency-sensitive paths.
+// The complexity is O(n/m), where m is the node size.
+func (s *Stack[T]) Reserve(n int) {
+	if n <= 0 || s.Cap()-s.len >= n {
+		return
+	}
+	if s.tail == nil || (s.len == 0 && cap(s.tail.v) == 0) {
+		s.setFirst()
+	}
+	if s.opts().growth != nil {
+		s.reserveNodes(n)
+		return
+	}
+
+	size := s.opts().nodeCap()
+	if cap(s.tail.v) < size {
+		s.growTail(n)
+	}
+	n -= s.Cap() - s.len
+	if n <= 0 {
+		return
+	}
+	k := (n + size - 1) / size
+	s.ownNodes()
+	s.nodes = slices.Grow(s.nodes, len(s.spare)+k)
+	s.spare = slices.Grow(s.spare, k)
+	for ; k > 0; k-- {
+		s.spare = append(s.spare, s.allocNode(size))
+	}
+}
 
-// Back returns the last element of stack d or nil if the stack is empty.
+// Back returns the last element of stack s or the zero value of T if the stack is empty.
 // The second, bool result indicates whether a valid value was returned;
-// if the stack is empty, false will be returnes.
+// if the stack is empty, false will be returned.
 // The complexity is O(1).
-func (s *Stack) Back() (interface{}, bool) {
+func (s *Stack[T]) Back() (T, bool) {
 	if s.len == 0 {
-		return nil, false
+		var zero T
+		return zero, false
+	}
+	return s.tail.v[s.top-1], true
+}
+
+// At returns the element at depth i of stack s, where depth 0 is the last
+// element (the one Back returns) and depth Len()-1 the first one.
+// The second, bool result indicates whether a valid value was returned;
+// if i is out of range, false will be returned.
+// The complexity is O(1).
+func (s *Stack[T]) At(i int) (T, bool) {
+	return s.Get(s.len - 1 - i)
+}
+
+// Get returns the element at index i of stack s, where index 0 is the first
+// element pushed into the stack and index Len()-1 the last one.
+// The second, bool result indicates whether a valid value was returned;
+// if i is out of range, false will be returned.
+// The complexity is O(1).
+func (s *Stack[T]) Get(i int) (T, bool) {
+	if i < 0 || i >= s.len {
+		var zero T
+		return zero, false
 	}
-	return s.tail.v[len(s.tail.v)-1], true
+	k, j := s.locate(i)
+	return s.nodes[k].v[j], true
+}
+
+// Set replaces the element at index i of stack s with v, where index 0 is the
+// first element pushed into the stack and index Len()-1 the last one.
+// Set returns false, leaving the stack unchanged, if i is out of range.
+// The complexity is O(1).
+func (s *Stack[T]) Set(i int, v T) bool {
+	if i < 0 || i >= s.len {
+		return false
+	}
+	s.sync()
+	k, j := s.locate(i)
+	n := s.nodes[k]
+	if n.g != s.gen {
+		n = s.own(k)
+	}
+	n.v[j] = v
+	s.mod++
+	return true
+}
+
+// PeekN copies up to n elements from the back of stack s into dst, without
+// removing them. dst[0] receives the last element, dst[1] the one below it
+// and so on. PeekN copies no more than len(dst) elements and returns the
+// number of elements copied.
+// The complexity is O(n).
+func (s *Stack[T]) PeekN(dst []T, n int) int {
+	n = min(n, len(dst), s.len)
+	s.sync()
+	c := 0
+	for k := len(s.nodes) - 1; c < n; k-- {
+		v := s.nodes[k].v
+		for j := len(v) - 1; j >= 0 && c < n; j-- {
+			dst[c] = v[j]
+			c++
+		}
+	}
+	return c
 }
 
 // Push adds value v to the the back of the stack.
 // The complexity is O(1).
-func (s *Stack) Push(v interface{}) {
+func (s *Stack[T]) Push(v T) {
+	if s.top < s.lim {
+		t := s.tail
+		t.v = t.v[:s.top+1]
+		t.v[s.top] = v
+		s.top++
+		s.len++
+		s.mod++
+		return
+	}
+	s.pushSlow(v)
+}
+
+// pushSlow implements Push when the tail is full or shared with snapshots,
+// when the stack has no nodes yet or when other operations than Push and Pop
+// changed the stack.
+func (s *Stack[T]) pushSlow(v T) {
+	s.sync()
 	if s.tail == nil {
-		s.tail = &node{v: make([]interface{}, 0, firstSliceSize)}
+		s.tail = &node[T]{v: make([]T, 0, s.opts().firstCap()), g: s.gen}
 		s.tail.p = s.tail
-	} else if len(s.tail.v) >= maxInternalSliceSize {
-		s.tail = &node{
-			v: make([]interface{}, 0, maxInternalSliceSize),
-			p: s.tail,
-		}
+		s.nodes = append(s.nodes, s.tail)
+	} else if len(s.tail.v) == cap(s.tail.v) {
+		s.grow(1)
+	} else if s.tail.g != s.gen {
+		s.own(len(s.nodes) - 1)
 	}
 	s.len++
+	s.mod++
 	s.tail.v = append(s.tail.v, v)
+	s.top, s.lim = len(s.tail.v), cap(s.tail.v)
+}
+
+// PushAll adds values vs to the back of the stack, in order, so the last
+// value in vs becomes the last element of the stack. PushAll fills the nodes
+// with bulk copies and, when new nodes are needed, allocates all of them upfront.
+// The complexity is O(len(vs)).
+func (s *Stack[T]) PushAll(vs ...T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	s.sync()
+	if s.tail == nil {
+		s.tail = &node[T]{g: s.gen}
+		s.tail.p = s.tail
+		s.nodes = append(s.nodes, s.tail)
+	} else if s.tail.g != s.gen && len(s.tail.v) < cap(s.tail.v) {
+		s.own(len(s.nodes) - 1)
+	}
+	if cap(s.tail.v)-len(s.tail.v) < len(vs) {
+		s.grow(len(vs))
+	}
+	s.len += len(vs)
+	s.mod++
+
+	// Fill the tail and the new nodes linked after it, if any.
+	i := len(s.nodes) - 1
+	for s.nodes[i] != s.tail {
+		i--
+	}
+	for ; len(vs) > 0; i++ {
+		s.tail = s.nodes[i]
+		c := copy(s.tail.v[len(s.tail.v):cap(s.tail.v)], vs)
+		s.tail.v = s.tail.v[:len(s.tail.v)+c]
+		vs = vs[c:]
+	}
+	s.top = len(s.tail.v)
+}
+
+// PushSeq adds the values yielded by seq to the back of the stack, in order,
+// so the last value yielded becomes the last element of the stack.
+// The complexity is O(n), where n is the number of values yielded by seq.
+func (s *Stack[T]) PushSeq(seq iter.Seq[T]) {
+	for v := range seq {
+		s.Push(v)
+	}
 }
 
 // Pop retrieves and removes the current element from the back of the stack.
 // The second, bool result indicates whether a valid value was returned;
-// if the stack is empty, false will be returnes.
+// if the stack is empty, false will be returned.
+// The complexity is O(1).
+func (s *Stack[T]) Pop() (T, bool) {
+	var zero T
+	if s.len == 0 {
+		return zero, false
+	}
+
+	s.len--
+	s.mod++
+	t := s.tail
+	s.top--
+	v := t.v[s.top]
+	if t.g == s.gen {
+		t.v[s.top] = zero // Avoid memory leaks
+	}
+	if s.top == 0 && t.p != t {
+		// Move to the previous slice. The emptied node is released by sync.
+		s.tail, s.top, s.lim = t.p, len(t.p.v), 0
+	}
+	return v, true
+}
+
+// PopN retrieves and removes up to n elements from the back of the stack,
+// moving them into dst. dst[0] receives the last element, dst[1] the one
+// below it and so on, which is the order repeated Pop calls would return them.
+// PopN moves no more than len(dst) elements and returns the number of
+// elements moved.
+// The complexity is O(n).
+func (s *Stack[T]) PopN(dst []T, n int) int {
+	n = min(n, len(dst), s.len)
+	if n <= 0 {
+		return 0
+	}
+
+	s.sync()
+	s.len -= n
+	s.mod++
+	c := 0
+	for c < n {
+		if s.tail.g != s.gen {
+			s.own(len(s.nodes) - 1)
+		}
+		tv := s.tail.v
+		k := min(n-c, len(tv))
+		for j := len(tv) - 1; j >= len(tv)-k; j-- {
+			dst[c] = tv[j]
+			c++
+		}
+		clear(tv[len(tv)-k:]) // Avoid memory leaks
+		s.tail.v = tv[:len(tv)-k]
+		if len(s.tail.v) == 0 && s.tail.p != s.tail {
+			s.tail = s.tail.p // Move to the previous slice.
+			s.release()
+		}
+	}
+	s.top = len(s.tail.v)
+	return n
+}
+
+// All returns an iterator over the stack values, from the top (back) of the
+// stack down to its bottom. The index yielded with each value is its depth:
+// 0 for the top value, Len()-1 for the bottom one.
+// All doesn't remove values from the stack. Pushing, popping or setting values
+// while iterating causes the iterator to panic.
+func (s *Stack[T]) All() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		s.sync()
+		mod := s.mod
+		nodes := s.nodes
+		i := 0
+		for k := len(nodes) - 1; k >= 0; k-- {
+			n := nodes[k]
+			for j := len(n.v) - 1; j >= 0; j-- {
+				if !yield(i, n.v[j]) {
+					return
+				}
+				if s.mod != mod {
+					panic(errModified)
+				}
+				i++
+			}
+		}
+	}
+}
+
+// Backward returns an iterator over the stack values, from the bottom of the
+// stack up to its top (back). The index yielded with each value is its depth,
+// the same index All yields for it, so indexes go from Len()-1 down to 0.
+// Backward doesn't remove values from the stack. Pushing, popping or setting
+// values while iterating causes the iterator to panic.
+func (s *Stack[T]) Backward() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		s.sync()
+		mod := s.mod
+		i := s.len - 1
+		for _, n := range s.nodes {
+			for _, v := range n.v {
+				if !yield(i, v) {
+					return
+				}
+				if s.mod != mod {
+					panic(errModified)
+				}
+				i--
+			}
+		}
+	}
+}
+
+// Drain returns an iterator that pops the stack values as they are consumed,
+// from the top (back) of the stack down to its bottom. Stopping the iteration
+// early leaves the values not yet consumed in the stack.
+// Values pushed while draining are popped and yielded as well.
+func (s *Stack[T]) Drain() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for s.len > 0 {
+			v, _ := s.Pop()
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Snapshot returns a read-only view of the current state of stack s.
+// The snapshot shares its nodes with s; instead of copying them upfront, s
+// copies a shared node the first time a Push or Set would change it.
+// Hence, taking a snapshot is O(1) and changes to s are never visible
+// through the snapshot.
+func (s *Stack[T]) Snapshot() *Snapshot[T] {
+	s.sync()
+	s.gen++
+	s.shared = true
+	return &Snapshot[T]{s: Stack[T]{tail: s.tail, nodes: s.nodes, len: s.len, top: s.top, cfg: s.cfg}}
+}
+
+// Clone returns a copy of stack s. The copy doesn't share any memory with s,
+// so each stack can be changed independently of the other.
+// The complexity is O(n), but values are copied node by node with bulk copies.
+func (s *Stack[T]) Clone() *Stack[T] {
+	s.sync()
+	c := &Stack[T]{len: s.len, top: s.top, cfg: s.cfg}
+	if s.tail == nil {
+		return c
+	}
+
+	c.nodes = make([]*node[T], len(s.nodes))
+	p := (*node[T])(nil)
+	for i, n := range s.nodes {
+		v := make([]T, len(n.v), cap(n.v))
+		copy(v, n.v)
+		p = &node[T]{v: v, p: p, i: n.i}
+		c.nodes[i] = p
+	}
+	c.nodes[0].p = c.nodes[0] // The first node points to itself.
+	c.tail = p
+	return c
+}
+
+// ToSlice returns a new slice with the elements of stack s, from its first
+// element to its last one, which is the order they were pushed.
+// The complexity is O(n).
+func (s *Stack[T]) ToSlice() []T {
+	return s.AppendTo(make([]T, 0, s.len))
+}
+
+// AppendTo appends the elements of stack s to dst, from its first element to
+// its last one, and returns the extended slice. AppendTo grows dst at most once.
+// The complexity is O(n).
+func (s *Stack[T]) AppendTo(dst []T) []T {
+	s.sync()
+	dst = slices.Grow(dst, s.len)
+	for _, n := range s.nodes {
+		dst = append(dst, n.v...)
+	}
+	return dst
+}
+
+// locate returns the directory index k of the node holding the element at
+// index i, and the index j of the element in that node.
+// Nodes sized by a growth policy are found with a binary search.
+func (s *Stack[T]) locate(i int) (k, j int) {
+	if s.opts().growth != nil {
+		i += s.nodes[0].i
+		k = sort.Search(len(s.nodes), func(k int) bool { return s.nodes[k].i > i }) - 1
+		return k, i - s.nodes[k].i
+	}
+	f := len(s.nodes[0].v)
+	if i < f {
+		return 0, i
+	}
+	i -= f
+	c := s.opts().nodeCap()
+	return 1 + i/c, i % c
+}
+
+// popBottom retrieves and removes the first element of the stack, at its bottom.
+// The element is sliced off the first node; once the first node is empty,
+// it's removed from the linked list and the directory, unless it's the tail.
+// The second, bool result indicates whether a valid value was returned;
+// if the stack is empty, false will be returned.
 // The complexity is O(1).
-func (s *Stack) Pop() (interface{}, bool) {
+func (s *Stack[T]) popBottom() (T, bool) {
+	var zero T
 	if s.len == 0 {
-		return nil, false
+		return zero, false
 	}
 
+	s.sync()
+	f := s.nodes[0]
+	if f.g != s.gen {
+		f = s.own(0)
+	}
 	s.len--
-	tp := len(s.tail.v) - 1
-	vp := &s.tail.v[tp]
-	v := *vp
-	*vp = nil // Avoid memory leaks
-	s.tail.v = s.tail.v[:tp]
-	if tp <= 0 {
-		s.tail = s.tail.p // Move to the previous slice.
+	s.mod++
+	v := f.v[0]
+	f.v[0] = zero // Avoid memory leaks
+	f.v = f.v[1:]
+	f.i++
+	if len(f.v) == 0 && f != s.tail {
+		s.ownNodes()
+		s.nodes[0] = nil
+		s.nodes = s.nodes[1:]
+		s.nodes[0].p = s.nodes[0] // The first node points to itself.
 	}
+	s.top = len(s.tail.v)
 	return v, true
 }
+
+// grow makes room for n more values after the tail, n >= 1.
+// The first node doubles in size until it's large enough for the n values or
+// it reaches the node size; from then on, new nodes are linked after the tail
+// and added to the directory. If the tail is full, it's moved to the first
+// new node.
+// Growing the first node explicitly, rather than relying on append, keeps
+// the node sizes independent of the runtime's size classes and of T.
+func (s *Stack[T]) grow(n int) {
+	if s.opts().growth != nil {
+		s.growNodes(n)
+		return
+	}
+
+	size := s.opts().nodeCap()
+	if cap(s.tail.v) < size {
+		s.growTail(n)
+	}
+	n -= cap(s.tail.v) - len(s.tail.v)
+	if n <= 0 {
+		return
+	}
+
+	k := (n + size - 1) / size
+	s.ownNodes()
+	s.nodes = slices.Grow(s.nodes, k)
+	first := len(s.nodes)
+	for p := s.tail; k > 0; k-- {
+		p = s.newNode(p, size)
+		s.nodes = append(s.nodes, p)
+	}
+	if len(s.tail.v) == cap(s.tail.v) {
+		s.tail = s.nodes[first]
+	}
+}
+
+// reserveNodes makes room for n more values, n >= 1, allocating spare nodes
+// of the sizes the stack's growth policy will ask for as the stack grows.
+// The nodes are stored in reverse order, so newNode takes them in order.
+// Spare nodes of other sizes are released.
+func (s *Stack[T]) reserveNodes(n int) {
+	s.releaseSpare(0)
+	var sizes []int
+	total := s.len + cap(s.tail.v) - len(s.tail.v)
+	for last := cap(s.tail.v); total < s.len+n; {
+		size := max(s.opts().growth.NodeSize(total, last), 1)
+		sizes = append(sizes, size)
+		total += size
+		last = size
+	}
+	s.ownNodes()
+	s.nodes = slices.Grow(s.nodes, len(sizes))
+	s.spare = slices.Grow(s.spare, len(sizes))
+	for i := len(sizes) - 1; i >= 0; i-- {
+		s.spare = append(s.spare, s.allocNode(sizes[i]))
+	}
+}
+
+// growTail grows the tail node, doubling its size until it's large enough for
+// n more values or it reaches the node size. Only the first node, and a tail
+// node reallocated by Shrink, can be smaller than the node size.
+func (s *Stack[T]) growTail(n int) {
+	if s.tail.g != s.gen {
+		s.own(len(s.nodes) - 1)
+	}
+	size := s.opts().nodeCap()
+	c := max(cap(s.tail.v), s.opts().firstCap())
+	for c < len(s.tail.v)+n && c < size {
+		c *= 2
+	}
+	c = min(c, size)
+	if c == cap(s.tail.v) {
+		return
+	}
+
+	var v []T
+	if c == size && s.pool() != nil {
+		if n := s.pool().get(size); n != nil {
+			v = n.v[:len(s.tail.v)]
+		}
+	}
+	if v == nil {
+		v = make([]T, len(s.tail.v), c)
+	}
+	copy(v, s.tail.v)
+	s.tail.v = v
+}
+
+// growNodes makes room for n more values after the tail, n >= 1, linking
+// new nodes sized by the stack's growth policy after the tail. An empty first
+// node with no room left, such as after values were removed from the bottom
+// of the stack, is replaced.
+func (s *Stack[T]) growNodes(n int) {
+	s.ownNodes()
+	if s.len == 0 && cap(s.tail.v) == 0 {
+		s.setFirst()
+	}
+
+	free := cap(s.tail.v) - len(s.tail.v)
+	first := len(s.nodes)
+	for p := s.tail; free < n; {
+		size := max(s.opts().growth.NodeSize(s.len+free, cap(p.v)), 1)
+		p = s.newNode(p, size)
+		s.nodes = append(s.nodes, p)
+		free += size
+	}
+	if first < len(s.nodes) && len(s.tail.v) == cap(s.tail.v) {
+		s.tail = s.nodes[first]
+	}
+}
+
+// setFirst replaces the nodes of the empty stack with a new first node.
+func (s *Stack[T]) setFirst() {
+	s.tail = &node[T]{v: make([]T, 0, s.opts().firstCap()), g: s.gen}
+	s.tail.p = s.tail
+	s.ownNodes()
+	clear(s.nodes)
+	s.nodes = append(s.nodes[:0], s.tail)
+}
+
+// newNode returns an empty node of the given size linked to p, reusing a
+// spare node if there's any.
+func (s *Stack[T]) newNode(p *node[T], size int) *node[T] {
+	var n *node[T]
+	if k := len(s.spare) - 1; k >= 0 && cap(s.spare[k].v) == size {
+		n = s.spare[k]
+		s.spare[k] = nil
+		s.spare = s.spare[:k]
+	} else {
+		n = s.allocNode(size)
+	}
+	n.p, n.g, n.i = p, s.gen, p.i+cap(p.v)
+	return n
+}
+
+// allocNode returns an empty, unlinked node of the given size, taken from the
+// stack's pool if there's any.
+func (s *Stack[T]) allocNode(size int) *node[T] {
+	if s.pool() != nil {
+		if n := s.pool().get(size); n != nil {
+			n.g = s.gen
+			return n
+		}
+	}
+	return &node[T]{v: make([]T, 0, size), g: s.gen}
+}
+
+// release removes the last node from the directory, a node emptied by Pop
+// or PopN after the tail was moved to the previous node.
+// The released node is kept as a spare node if the stack has room for it.
+// Nodes shared with snapshots can't be reused, so they're simply dropped.
+func (s *Stack[T]) release() {
+	s.ownNodes()
+	k := len(s.nodes) - 1
+	n := s.nodes[k]
+	s.nodes[k] = nil
+	s.nodes = s.nodes[:k]
+	if n.g != s.gen {
+		return
+	}
+
+	n.v, n.p = n.v[:0], nil
+	if len(s.spare) < s.opts().spareCap() && (s.opts().growth != nil || cap(n.v) == s.opts().nodeCap()) {
+		s.spare = append(s.spare, n)
+	} else if s.pool() != nil {
+		s.pool().put(n)
+	}
+}
+
+// sync brings the nodes back in line with the tail length Push and Pop keep
+// in top: it releases the nodes Pop emptied, which are left in the directory
+// after the tail, and slices the tail to top values. A tail shared with
+// snapshots gets a new node header, still sharing its values.
+// sync doesn't change a stack already in line, such as a snapshot, so
+// snapshots can be read while their stack changes.
+func (s *Stack[T]) sync() {
+	if s.lim != 0 {
+		s.lim = 0 // The caller may change the tail.
+	}
+	t := s.tail
+	if t == nil {
+		return
+	}
+	for s.nodes[len(s.nodes)-1] != t {
+		s.release()
+	}
+	if len(t.v) == s.top {
+		return
+	}
+
+	if t.g == s.gen {
+		t.v = t.v[:s.top] // Pop already cleared the values past top.
+		return
+	}
+	c := &node[T]{v: t.v[:s.top], p: t.p, g: t.g, i: t.i}
+	if t.p == t {
+		c.p = c // The first node points to itself.
+	}
+	s.ownNodes()
+	s.nodes[len(s.nodes)-1] = c
+	s.tail = c
+}
+
+// observe reports the length of the stack to its growth policy, if the policy
+// learns from it.
+func (s *Stack[T]) observe() {
+	if o, ok := s.opts().growth.(observer); ok {
+		o.observe(s.len)
+	}
+}
+
+// releaseSpare releases the spare nodes beyond the first k ones, returning
+// them to the stack's pool, if any.
+func (s *Stack[T]) releaseSpare(k int) {
+	if len(s.spare) <= k {
+		return
+	}
+	if s.pool() != nil {
+		for _, n := range s.spare[k:] {
+			s.pool().put(n)
+		}
+	}
+	clear(s.spare[k:])
+	s.spare = s.spare[:k]
+}
+
+// recycle returns the nodes of the stack to its pool, except for the nodes
+// that may be shared with snapshots and the nodes too small to be pooled.
+func (s *Stack[T]) recycle() {
+	for _, n := range s.nodes {
+		if n.g == s.gen && (s.opts().growth != nil || cap(n.v) == s.opts().nodeCap()) {
+			s.pool().put(n)
+		}
+	}
+	for _, n := range s.spare {
+		s.pool().put(n)
+	}
+}
+
+// own replaces node i, which may be shared with snapshots, with a copy of it
+// owned by the current generation, and returns the copy.
+func (s *Stack[T]) own(i int) *node[T] {
+	return s.resize(i, cap(s.nodes[i].v))
+}
+
+// resize replaces node i, which may be shared with snapshots, with a copy of
+// it of capacity size, which must fit its values, owned by the current generation,
+// and returns the copy.
+// Nodes only link to their previous node, so the node after it is relinked to
+// the copy. Snapshots never follow the links, so relinking a shared node is safe.
+func (s *Stack[T]) resize(i, size int) *node[T] {
+	s.ownNodes()
+	n := s.nodes[i]
+	v := make([]T, len(n.v), size)
+	copy(v, n.v)
+	c := &node[T]{v: v, p: n.p, g: s.gen, i: n.i}
+	if i == 0 {
+		c.p = c // The first node points to itself.
+	}
+	if i+1 < len(s.nodes) {
+		s.nodes[i+1].p = c
+	}
+	if s.tail == n {
+		s.tail = c
+	}
+	s.nodes[i] = c
+	return c
+}
+
+// ownNodes copies the nodes directory if it may be shared with snapshots.
+func (s *Stack[T]) ownNodes() {
+	if s.shared {
+		s.nodes = slices.Clone(s.nodes)
+		s.shared = false
+	}
+}
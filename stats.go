@@ -0,0 +1,75 @@
+// Copyright (c) 2018 ef-ds
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package stack
+
+import "unsafe"
+
+// Stats holds the memory statistics of a stack.
+type Stats struct {
+	// Nodes is the number of nodes holding the stack's elements.
+	Nodes int
+
+	// SpareNodes is the number of empty nodes kept for reuse.
+	SpareNodes int
+
+	// Len is the number of elements in the stack.
+	Len int
+
+	// Cap is the number of elements the stack's nodes, including its spare
+	// nodes, can hold. Cap - Len is the capacity allocated but unused.
+	Cap int
+
+	// FirstNodeCap is the capacity of the first node.
+	FirstNodeCap int
+
+	// Bytes is the estimated memory footprint of the stack, in bytes: the
+	// size of its nodes, their slots and its nodes directory. Nodes shared
+	// with snapshots are counted in full.
+	Bytes int
+}
+
+// Stats returns the memory statistics of stack s, walking its nodes from the
+// last node to the first one.
+// The complexity is O(k), where k is the number of nodes.
+func (s *Stack[T]) Stats() Stats {
+	s.sync()
+	var st Stats
+	var v T
+	slot := int(unsafe.Sizeof(v))
+	size := int(unsafe.Sizeof(node[T]{}))
+	for n := s.tail; n != nil; n = n.p {
+		st.Nodes++
+		st.Cap += cap(n.v)
+		st.Bytes += size + cap(n.v)*slot
+		if n.p == n {
+			st.FirstNodeCap = cap(n.v)
+			break
+		}
+	}
+	for _, n := range s.spare {
+		st.SpareNodes++
+		st.Cap += cap(n.v)
+		st.Bytes += size + cap(n.v)*slot
+	}
+	st.Len = s.len
+	st.Bytes += (cap(s.nodes) + cap(s.spare)) * int(unsafe.Sizeof(s.tail))
+	return st
+}
@@ -0,0 +1,293 @@
+// Copyright (c) 2018 ef-ds
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package stack
+
+import (
+	"iter"
+	"slices"
+	"sync"
+)
+
+// SyncStack implements an unbounded, dynamically growing Last-In-First-Out
+// (LIFO) stack data structure that is safe for concurrent use by multiple
+// goroutines. It wraps a Stack, guarding it with a mutex.
+// The zero value for SyncStack is an empty stack ready to use.
+//
+// Besides the Stack methods, SyncStack offers compound operations, such as
+// PopIf and PushIfLenBelow, that check and change the stack atomically.
+type SyncStack[T any] struct {
+	mu sync.Mutex
+	s  Stack[T]
+}
+
+// NewSync returns an initialized, safe for concurrent use stack.
+func NewSync[T any]() *SyncStack[T] {
+	return new(SyncStack[T])
+}
+
+// Init initializes or clears stack s.
+func (s *SyncStack[T]) Init() *SyncStack[T] {
+	s.mu.Lock()
+	s.s.Init()
+	s.mu.Unlock()
+	return s
+}
+
+// SetSpareNodes sets the maximum number of spare nodes stack s keeps.
+// See Stack.SetSpareNodes.
+func (s *SyncStack[T]) SetSpareNodes(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.s.SetSpareNodes(n)
+}
+
+// Len returns the number of elements of stack s.
+// The complexity is O(1).
+func (s *SyncStack[T]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.s.Len()
+}
+
+// Cap returns the capacity of stack s. See Stack.Cap.
+// The complexity is O(k), where k is the number of spare nodes.
+func (s *SyncStack[T]) Cap() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.s.Cap()
+}
+
+// Reserve makes room for n more elements in stack s. See Stack.Reserve.
+// The complexity is O(n/m), where m is the node size.
+func (s *SyncStack[T]) Reserve(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.s.Reserve(n)
+}
+
+// Shrink releases the memory stack s doesn't need. See Stack.Shrink.
+// The complexity is O(m), where m is the node size.
+func (s *SyncStack[T]) Shrink() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.s.Shrink()
+}
+
+// Reset removes all elements of stack s, keeping its nodes. See Stack.Reset.
+// The complexity is O(n).
+func (s *SyncStack[T]) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.s.Reset()
+}
+
+// Stats returns the memory statistics of stack s. See Stack.Stats.
+// The complexity is O(k), where k is the number of nodes.
+func (s *SyncStack[T]) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.s.Stats()
+}
+
+// Back returns the last element of stack s. See Stack.Back.
+// The complexity is O(1).
+func (s *SyncStack[T]) Back() (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.s.Back()
+}
+
+// At returns the element at depth i of stack s. See Stack.At.
+// The complexity is O(1).
+func (s *SyncStack[T]) At(i int) (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.s.At(i)
+}
+
+// Get returns the element at index i of stack s. See Stack.Get.
+// The complexity is O(1).
+func (s *SyncStack[T]) Get(i int) (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.s.Get(i)
+}
+
+// Set replaces the element at index i of stack s with v. See Stack.Set.
+// The complexity is O(1).
+func (s *SyncStack[T]) Set(i int, v T) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.s.Set(i, v)
+}
+
+// PeekN copies up to n elements from the back of stack s into dst.
+// See Stack.PeekN.
+// The complexity is O(n).
+func (s *SyncStack[T]) PeekN(dst []T, n int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.s.PeekN(dst, n)
+}
+
+// Push adds value v to the the back of the stack.
+// The complexity is O(1).
+func (s *SyncStack[T]) Push(v T) {
+	s.mu.Lock()
+	s.s.Push(v)
+	s.mu.Unlock()
+}
+
+// PushAll adds values vs to the back of the stack, in order. The values are
+// pushed atomically: no other operation sees only part of them pushed.
+// The complexity is O(len(vs)).
+func (s *SyncStack[T]) PushAll(vs ...T) {
+	s.mu.Lock()
+	s.s.PushAll(vs...)
+	s.mu.Unlock()
+}
+
+// PushSeq adds the values yielded by seq to the back of the stack, in order.
+// The values are collected before locking the stack, so seq may safely use
+// the stack, and are then pushed atomically, like PushAll does.
+// The complexity is O(n), where n is the number of values yielded by seq.
+func (s *SyncStack[T]) PushSeq(seq iter.Seq[T]) {
+	s.PushAll(slices.Collect(seq)...)
+}
+
+// PushIfLenBelow adds value v to the back of the stack only if the stack
+// holds less than n elements, and reports whether v was pushed.
+// The complexity is O(1).
+func (s *SyncStack[T]) PushIfLenBelow(n int, v T) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.s.Len() >= n {
+		return false
+	}
+	s.s.Push(v)
+	return true
+}
+
+// Pop retrieves and removes the current element from the back of the stack.
+// See Stack.Pop.
+// The complexity is O(1).
+func (s *SyncStack[T]) Pop() (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.s.Pop()
+}
+
+// PopIf retrieves and removes the current element from the back of the stack
+// only if pred returns true for it. The second, bool result indicates whether
+// the element was removed; if the stack is empty or pred returns false,
+// false will be returned and the stack is left unchanged.
+// pred is called with the stack locked, so it must not use the stack.
+// The complexity is O(1), plus the cost of pred.
+func (s *SyncStack[T]) PopIf(pred func(T) bool) (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.s.Back(); !ok || !pred(v) {
+		var zero T
+		return zero, false
+	}
+	return s.s.Pop()
+}
+
+// PopN retrieves and removes up to n elements from the back of the stack,
+// moving them into dst. The elements are removed atomically.
+// See Stack.PopN.
+// The complexity is O(n).
+func (s *SyncStack[T]) PopN(dst []T, n int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.s.PopN(dst, n)
+}
+
+// All returns an iterator over the stack values, from top to bottom.
+// The iterator walks a snapshot of the stack taken when the iteration starts,
+// so the stack isn't locked while iterating and can be changed concurrently,
+// including by the loop body itself.
+// See Stack.All.
+func (s *SyncStack[T]) All() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		s.Snapshot().All()(yield)
+	}
+}
+
+// Backward returns an iterator over the stack values, from bottom to top.
+// Like All, the iterator walks a snapshot of the stack taken when the
+// iteration starts.
+// See Stack.Backward.
+func (s *SyncStack[T]) Backward() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		s.Snapshot().Backward()(yield)
+	}
+}
+
+// Drain returns an iterator that pops the stack values as they are consumed.
+// Each value is popped atomically, so concurrent consumers draining the same
+// stack never receive the same value.
+// See Stack.Drain.
+func (s *SyncStack[T]) Drain() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for {
+			v, ok := s.Pop()
+			if !ok || !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Snapshot returns a read-only view of the current state of stack s.
+// See Stack.Snapshot.
+// The complexity is O(1).
+func (s *SyncStack[T]) Snapshot() *Snapshot[T] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.s.Snapshot()
+}
+
+// Clone returns a copy of stack s. See Stack.Clone.
+// The complexity is O(n).
+func (s *SyncStack[T]) Clone() *SyncStack[T] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &SyncStack[T]{s: *s.s.Clone()}
+}
+
+// ToSlice returns a new slice with the elements of stack s, from its first
+// element to its last one. See Stack.ToSlice.
+// The complexity is O(n).
+func (s *SyncStack[T]) ToSlice() []T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.s.ToSlice()
+}
+
+// AppendTo appends the elements of stack s to dst, from its first element to
+// its last one, and returns the extended slice. See Stack.AppendTo.
+// The complexity is O(n).
+func (s *SyncStack[T]) AppendTo(dst []T) []T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.s.AppendTo(dst)
+}
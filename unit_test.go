@@ -21,7 +21,10 @@
 package stack
 
 import (
+	"context"
+	"slices"
 	"testing"
+	"time"
 )
 
 const (
@@ -30,12 +33,12 @@ const (
 )
 
 func TestNewShouldReturnInitiazedInstanceOfstack(t *testing.T) {
-	s := New()
+	s := New[int]()
 	assertInvariants(t, s, nil)
 }
 
 func TestInvariantsWhenEmptyInMiddleOfSlice(t *testing.T) {
-	s := new(Stack)
+	s := new(Stack[int])
 	s.Push(0)
 	assertInvariants(t, s, nil)
 	s.Push(1)
@@ -49,7 +52,7 @@ func TestInvariantsWhenEmptyInMiddleOfSlice(t *testing.T) {
 }
 
 func TestPushPopShouldHaveAllInternalLinksInARing(t *testing.T) {
-	s := New()
+	s := New[int]()
 	pushValue, extraAddedItems := 0, 0
 
 	// Push maxInternalSliceSize items to fill the first array
@@ -84,7 +87,7 @@ func TestPushPopShouldHaveAllInternalLinksInARing(t *testing.T) {
 	// Pop one item to force moving the tail to the middle slice. This also means the old tail
 	// slice should have no items now
 	popValue := s.Len()
-	if v, ok := s.Pop(); !ok || v.(int) != popValue {
+	if v, ok := s.Pop(); !ok || v != popValue {
 		t.Errorf("Expected: %d; Got: %d", popValue, v)
 	}
 	popValue--
@@ -92,7 +95,7 @@ func TestPushPopShouldHaveAllInternalLinksInARing(t *testing.T) {
 
 	// Pop maxInternalSliceSize-1 items to empty the tail (middle) slice
 	for i := 1; i <= maxInternalSliceSize-1; i++ {
-		if v, ok := s.Pop(); !ok || v.(int) != popValue {
+		if v, ok := s.Pop(); !ok || v != popValue {
 			t.Errorf("Expected: %d; Got: %d", popValue, v)
 		}
 		popValue--
@@ -101,7 +104,7 @@ func TestPushPopShouldHaveAllInternalLinksInARing(t *testing.T) {
 
 	// Pop one extra item to force moving the tail to the head (first) slice. This also means the old tail
 	// slice should have no items now.
-	if v, ok := s.Pop(); !ok || v.(int) != popValue {
+	if v, ok := s.Pop(); !ok || v != popValue {
 		t.Errorf("Expected: %d; Got: %d", popValue, v)
 	}
 	popValue--
@@ -109,7 +112,7 @@ func TestPushPopShouldHaveAllInternalLinksInARing(t *testing.T) {
 
 	// Pop maxFirstSliceSize-1 items to empty the head (first) slice
 	for i := 1; i <= maxInternalSliceSize; i++ {
-		if v, ok := s.Pop(); !ok || v.(int) != popValue {
+		if v, ok := s.Pop(); !ok || v != popValue {
 			t.Errorf("Expected: %d; Got: %d", popValue, v)
 		}
 		popValue--
@@ -128,10 +131,942 @@ func TestPushPopShouldHaveAllInternalLinksInARing(t *testing.T) {
 	}
 }
 
+func TestFirstSliceShouldDoubleUntilMaxInternalSliceSize(t *testing.T) {
+	s := New[int]()
+	want := firstSliceSize
+	for i := 0; i < maxInternalSliceSize; i++ {
+		if i == want {
+			want *= 2
+		}
+		s.Push(i)
+		if cap(s.tail.v) != want {
+			t.Fatalf("Unexpected first slice size after %d pushes; Expected: %d; Got: %d", i+1, want, cap(s.tail.v))
+		}
+	}
+	if s.tail.p != s.tail {
+		t.Error("Expected the first slice to point to itself")
+	}
+
+	s.Push(maxInternalSliceSize)
+	if s.tail.p == s.tail || cap(s.tail.v) != maxInternalSliceSize {
+		t.Errorf("Expected a new %d sized slice; Got: %d", maxInternalSliceSize, cap(s.tail.v))
+	}
+}
+
+func TestNodesShouldMatchLinkedListWhenPushingAndPopping(t *testing.T) {
+	s := New[int]()
+	val := func(i int) int { return i }
+	for i := 0; i < pushCount; i++ {
+		s.Push(i)
+		if i%(maxInternalSliceSize/2) == 0 {
+			assertInvariants(t, s, val)
+		}
+	}
+	assertInvariants(t, s, val)
+	if len(s.nodes) != pushCount/maxInternalSliceSize {
+		t.Errorf("Expected: %d nodes; Got: %d", pushCount/maxInternalSliceSize, len(s.nodes))
+	}
+
+	for s.Len() > 0 {
+		s.Pop()
+		if s.Len()%(maxInternalSliceSize/2) == 0 {
+			assertInvariants(t, s, val)
+		}
+	}
+	if len(s.nodes) != 1 {
+		t.Errorf("Expected: 1 node; Got: %d", len(s.nodes))
+	}
+}
+
+func TestPushAllPopNShouldKeepInvariants(t *testing.T) {
+	s := New[int]()
+	val := func(i int) int { return i }
+	next := 0
+	for _, count := range []int{3, 5, 1, 600, 0, 1200, 423, 2} {
+		vs := make([]int, count)
+		for i := range vs {
+			vs[i] = next
+			next++
+		}
+		s.PushAll(vs...)
+		assertInvariants(t, s, val)
+	}
+
+	dst := make([]int, 700)
+	for _, count := range []int{1, 700, 2, 513, 511, 500} {
+		s.PopN(dst, count)
+		next -= count
+		assertInvariants(t, s, val)
+	}
+	if s.Len() != next {
+		t.Errorf("Expected: %d; Got: %d", next, s.Len())
+	}
+}
+
+func TestPopNShouldClearPoppedValues(t *testing.T) {
+	s := New[*int]()
+	for i := 0; i < maxInternalSliceSize+10; i++ {
+		s.Push(new(int))
+	}
+
+	dst := make([]*int, 20)
+	s.PopN(dst, 20)
+	if v := s.tail.v[:maxInternalSliceSize][maxInternalSliceSize-10:]; slices.ContainsFunc(v, func(p *int) bool { return p != nil }) {
+		t.Error("Expected: popped values to be cleared; Got: non-nil values")
+	}
+}
+
+func TestFromSliceShouldSizeNodesLikePush(t *testing.T) {
+	for count, want := range map[int]int{1: firstSliceSize, 9: firstSliceSize * 2, 100: 128, 512: maxInternalSliceSize} {
+		s := FromSlice(make([]int, count))
+		if len(s.nodes) != 1 || cap(s.tail.v) != want {
+			t.Errorf("Expected: 1 node of size %d for %d values; Got: %d nodes of size %d", want, count, len(s.nodes), cap(s.tail.v))
+		}
+		assertInvariants(t, s, nil)
+	}
+
+	s := FromSlice(make([]int, pushCount+1))
+	if len(s.nodes) != pushCount/maxInternalSliceSize+1 {
+		t.Errorf("Expected: %d nodes; Got: %d", pushCount/maxInternalSliceSize+1, len(s.nodes))
+	}
+	assertInvariants(t, s, func(int) int { return 0 })
+}
+
+func TestCloneShouldRebuildLinksAndKeepSliceSizes(t *testing.T) {
+	for _, count := range []int{1, 10, maxInternalSliceSize, pushCount + 1} {
+		s := New[int]()
+		for i := 0; i < count; i++ {
+			s.Push(i)
+		}
+
+		c := s.Clone()
+		assertInvariants(t, c, func(i int) int { return i })
+		if len(c.nodes) != len(s.nodes) {
+			t.Fatalf("Expected: %d nodes; Got: %d", len(s.nodes), len(c.nodes))
+		}
+		for i, n := range c.nodes {
+			if n == s.nodes[i] || cap(n.v) != cap(s.nodes[i].v) {
+				t.Errorf("Expected: new node of size %d; Got: shared node %t of size %d", cap(s.nodes[i].v), n == s.nodes[i], cap(n.v))
+			}
+		}
+		if c.nodes[0].p != c.nodes[0] {
+			t.Error("Expected the first node to point to itself")
+		}
+	}
+}
+
+func TestSnapshotShouldCopyOnlyChangedNodes(t *testing.T) {
+	s := New[int]()
+	for i := 0; i < pushCount+1; i++ {
+		s.Push(i)
+	}
+
+	nodes := slices.Clone(s.nodes)
+	sn := s.Snapshot()
+	if &sn.s.nodes[0] != &s.nodes[0] {
+		t.Error("Expected: snapshot to share the nodes directory")
+	}
+
+	s.Set(maxInternalSliceSize, -1)
+	assertInvariants(t, s, nil)
+	for i, n := range s.nodes {
+		if copied := n != nodes[i]; copied != (i == 1) {
+			t.Errorf("Unexpected copy of node %d; Expected: %t; Got: %t", i, i == 1, copied)
+		}
+	}
+	if &sn.s.nodes[0] == &s.nodes[0] {
+		t.Error("Expected: stack to copy the nodes directory")
+	}
+
+	// Popping the last value of the tail releases it.
+	s.Pop()
+	assertInvariants(t, s, nil)
+	if len(s.nodes) != len(nodes)-1 || s.tail != nodes[len(nodes)-2] {
+		t.Error("Expected: tail to move to the previous, shared node")
+	}
+
+	// Popping from a shared tail doesn't copy its values: the tail only gets
+	// a node header of its own once the stack is synced.
+	s.Pop()
+	if s.tail != nodes[len(nodes)-2] {
+		t.Error("Expected: tail not to be copied by Pop")
+	}
+	assertInvariants(t, s, nil)
+	if s.tail == nodes[len(nodes)-2] || &s.tail.v[0] != &nodes[len(nodes)-2].v[0] {
+		t.Error("Expected: tail to get a new node header sharing its values")
+	}
+
+	// Pushing copies the shared tail; nodes created or copied after the
+	// snapshot aren't copied again.
+	s.Push(0)
+	if &s.tail.v[0] == &nodes[len(nodes)-2].v[0] {
+		t.Error("Expected: tail to be copied before changing")
+	}
+	tail := s.tail
+	s.Push(0)
+	s.Pop()
+	if s.tail != tail {
+		t.Error("Expected: tail not to be copied")
+	}
+	for i, n := range sn.s.nodes {
+		if n != nodes[i] {
+			t.Errorf("Expected: snapshot node %d not to change", i)
+		}
+	}
+}
+
+func TestSnapshotOfFirstNodeShouldKeepItPointingToItself(t *testing.T) {
+	s := New[int]()
+	s.Push(1)
+	first := s.tail
+	s.Snapshot()
+
+	for i := 0; i < firstSliceSize*2; i++ {
+		s.Push(i)
+	}
+	if s.tail == first || s.tail.p != s.tail {
+		t.Error("Expected: first node copied and pointing to itself")
+	}
+	if len(first.v) != 1 || first.p != first {
+		t.Error("Expected: shared first node not to change")
+	}
+	assertInvariants(t, s, nil)
+}
+
+func TestPersistentStackShouldShareNodesBetweenVersions(t *testing.T) {
+	var ps PersistentStack[int]
+	for i := 0; i < firstSliceSize; i++ {
+		ps = ps.Push(i)
+	}
+	first := ps.c
+	if cap(first.v) != firstSliceSize || first.p != nil {
+		t.Fatalf("Expected: a single node of size %d; Got: size %d", firstSliceSize, cap(first.v))
+	}
+
+	// A full node is linked to a new, larger one.
+	ps2 := ps.Push(firstSliceSize)
+	if ps2.c.p != first || ps2.c.pn != firstSliceSize || cap(ps2.c.v) != firstSliceSize*2 {
+		t.Errorf("Expected: new node of size %d linked to the first one; Got: size %d", firstSliceSize*2, cap(ps2.c.v))
+	}
+
+	// Pushing on top of the latest version reuses its node.
+	popped, _, _ := ps.Pop()
+	popped, _, _ = popped.Pop()
+	if popped.c != first || popped.n != firstSliceSize-2 {
+		t.Fatalf("Expected: first node with %d values; Got: %d values", firstSliceSize-2, popped.n)
+	}
+
+	// Pushing on top of an older version links a new node to the shared one.
+	ps3 := popped.Push(-1)
+	if ps3.c == first || ps3.c.p != first || ps3.c.pn != firstSliceSize-2 {
+		t.Error("Expected: new node linked to the shared one")
+	}
+	if ps3.c.used.Load() != 1 || first.used.Load() != firstSliceSize {
+		t.Errorf("Expected: 1 and %d used slots; Got: %d and %d", firstSliceSize, ps3.c.used.Load(), first.used.Load())
+	}
+	if v, _ := ps.Back(); v != firstSliceSize-1 {
+		t.Errorf("Expected: %d; Got: %d", firstSliceSize-1, v)
+	}
+	ps4 := ps3.Push(-2)
+	if ps4.c != ps3.c {
+		t.Error("Expected: node reused by the latest version")
+	}
+}
+
+func TestPersistentStackNodesShouldGrowUpToMaxInternalSliceSize(t *testing.T) {
+	var ps PersistentStack[int]
+	for i := 0; i < pushCount; i++ {
+		ps = ps.Push(i)
+	}
+
+	want := maxInternalSliceSize
+	for c := ps.c; c.p != nil; c = c.p {
+		if cap(c.v) > maxInternalSliceSize || c.pn != cap(c.p.v) {
+			t.Errorf("Unexpected node; size: %d; previous node values: %d", cap(c.v), c.pn)
+		}
+		want = cap(c.p.v)
+	}
+	if want != firstSliceSize {
+		t.Errorf("Expected: first node of size %d; Got: %d", firstSliceSize, want)
+	}
+}
+
+func TestPersistentStackBranchesShouldStartSmallNodes(t *testing.T) {
+	var ps PersistentStack[int]
+	for i := 0; i < pushCount; i++ {
+		ps = ps.Push(i)
+	}
+	ps, _, _ = ps.Pop()
+	ps.Push(-1) // Claims the free slot, so the versions below branch off.
+
+	var b PersistentStack[int]
+	allocs := testing.AllocsPerRun(100, func() {
+		b = ps.Push(-1)
+	})
+	if allocs != 2 || cap(b.c.v) != firstSliceSize {
+		t.Errorf("Expected: 2 allocations for a node of size %d; Got: %v allocations for size %d", firstSliceSize, allocs, cap(b.c.v))
+	}
+
+	// The branch's nodes grow as the branch keeps growing.
+	for i := 0; i < firstSliceSize; i++ {
+		b = b.Push(i)
+	}
+	if cap(b.c.v) != firstSliceSize*2 || cap(b.c.p.v) != firstSliceSize {
+		t.Errorf("Expected: node of size %d after a node of size %d; Got: %d and %d", firstSliceSize*2, firstSliceSize, cap(b.c.v), cap(b.c.p.v))
+	}
+}
+
+func TestConcurrentStackPushAllShouldLinkChunkNodes(t *testing.T) {
+	var s ConcurrentStack[int]
+	s.Push(0)
+	first := s.top.Load()
+	s.PushAll(1, 2, 3)
+
+	n := s.top.Load()
+	for i := 3; i > 0; i-- {
+		if n.v != i || n.len != i+1 {
+			t.Errorf("Expected: value %d at length %d; Got: %d at %d", i, i+1, n.v, n.len)
+		}
+		n = n.p
+	}
+	if n != first {
+		t.Error("Expected: chunk linked to the previous node")
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		s.Push(4)
+		s.Pop()
+		s.Pop()
+		s.PushAll(5)
+	})
+	if allocs != 2 {
+		t.Errorf("Expected: Push and PushAll to allocate a node each and Pop none; Got: %v allocations", allocs)
+	}
+}
+
+func TestEliminationExchangeShouldHandOverValues(t *testing.T) {
+	var s EliminationStack[int]
+
+	// With no Pop around, the offer is withdrawn.
+	n := &cnode[int]{v: 1}
+	if s.exchangePush(n) {
+		t.Error("Expected: offer withdrawn; Got: taken")
+	}
+	for i := range s.slots {
+		if s.slots[i].n.Load() != nil {
+			t.Fatalf("Expected: slot %d to be empty", i)
+		}
+	}
+
+	// With no Push around, no value is taken.
+	if _, ok := s.exchangePop(); ok {
+		t.Error("Expected: no value; Got: value")
+	}
+
+	// A Pop takes the value offered in the slot it chooses.
+	for i := range s.slots {
+		s.slots[i].n.Store(n)
+	}
+	if v, ok := s.exchangePop(); !ok || v != 1 {
+		t.Errorf("Expected: 1; Got: %d", v)
+	}
+	taken := 0
+	for i := range s.slots {
+		if s.slots[i].n.Load() == nil {
+			taken++
+		}
+	}
+	if taken != 1 {
+		t.Errorf("Expected: 1 slot taken; Got: %d", taken)
+	}
+	if s.Len() != 0 {
+		t.Errorf("Expected: 0; Got: %d", s.Len())
+	}
+}
+
+func TestEliminationExchangeShouldMatchPushAndPop(t *testing.T) {
+	var s EliminationStack[int]
+	done := make(chan int)
+	go func() {
+		for {
+			if v, ok := s.exchangePop(); ok {
+				done <- v
+				return
+			}
+		}
+	}()
+
+	n := &cnode[int]{v: 7}
+	for !s.exchangePush(n) {
+	}
+	if v := <-done; v != 7 {
+		t.Errorf("Expected: 7; Got: %d", v)
+	}
+}
+
+func TestPopBottomShouldKeepInvariants(t *testing.T) {
+	var s Stack[int]
+	for i := 0; i < pushCount; i++ {
+		s.Push(i)
+	}
+	snap := s.Snapshot()
+
+	for i := 0; i < pushCount-1; i++ {
+		if v, ok := s.popBottom(); !ok || v != i {
+			t.Fatalf("Expected: %d; Got: %d", i, v)
+		}
+		assertInvariants(t, &s, func(j int) int { return i + 1 + j })
+		if i == 0 {
+			s.Push(pushCount)
+			s.Pop()
+		}
+	}
+	if len(s.nodes) != 1 || s.tail != s.nodes[0] {
+		t.Errorf("Expected: a single node; Got: %d nodes", len(s.nodes))
+	}
+
+	// Popping from the bottom doesn't change the snapshot.
+	for i := 0; i < pushCount; i++ {
+		if v, ok := snap.Get(i); !ok || v != i {
+			t.Fatalf("Expected: %d; Got: %d", i, v)
+		}
+	}
+
+	// The remaining node grows back as values are pushed.
+	for i := 0; i < pushCount; i++ {
+		s.Push(pushCount + i)
+	}
+	assertInvariants(t, &s, func(j int) int { return pushCount - 1 + j })
+	for s.Len() > 0 {
+		s.popBottom()
+	}
+	assertInvariants(t, &s, nil)
+	if _, ok := s.popBottom(); ok {
+		t.Error("Expected: no value")
+	}
+}
+
+func TestPushPopAtNodeBoundaryShouldReuseSpareNode(t *testing.T) {
+	var s Stack[int]
+	for i := 0; i < maxInternalSliceSize; i++ {
+		s.Push(i)
+	}
+	s.Push(maxInternalSliceSize)
+	n := s.tail
+	s.Pop()
+	s.sync() // Pop leaves the emptied node to be released by sync.
+	if len(s.spare) != 1 || s.spare[0] != n {
+		t.Fatalf("Expected: the released node to be kept as spare; Got: %d spare nodes", len(s.spare))
+	}
+	assertInvariants(t, &s, func(i int) int { return i })
+
+	s.Push(maxInternalSliceSize)
+	if s.tail != n || len(s.spare) != 0 {
+		t.Errorf("Expected: the spare node to be reused; Got: %d spare nodes", len(s.spare))
+	}
+	assertInvariants(t, &s, func(i int) int { return i })
+
+	allocs := testing.AllocsPerRun(100, func() {
+		s.Pop()
+		s.Push(maxInternalSliceSize)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected: no allocations; Got: %v", allocs)
+	}
+}
+
+func TestSpareNodesShouldBeReleasedAsStackShrinks(t *testing.T) {
+	var s Stack[int]
+	s.SetSpareNodes(2)
+	for i := 0; i < pushCount; i++ {
+		s.Push(i)
+	}
+	for s.Len() > 0 {
+		s.Pop()
+		assertInvariants(t, &s, func(i int) int { return i })
+	}
+	if len(s.spare) != 2 {
+		t.Errorf("Expected: 2 spare nodes; Got: %d", len(s.spare))
+	}
+
+	s.SetSpareNodes(0)
+	if len(s.spare) != 0 {
+		t.Errorf("Expected: no spare nodes; Got: %d", len(s.spare))
+	}
+	for i := 0; i < pushCount; i++ {
+		s.Push(i)
+	}
+	for s.Len() > 0 {
+		s.Pop()
+	}
+	if len(s.spare) != 0 {
+		t.Errorf("Expected: no spare nodes; Got: %d", len(s.spare))
+	}
+	s.Init()
+	if s.opts().spareCap() != 0 {
+		t.Errorf("Expected: Init to keep the spare nodes setting; Got: %d", s.opts().spareCap())
+	}
+}
+
+func TestSettingsShouldOnlyBeAllocatedWhenNotDefault(t *testing.T) {
+	if s := NewWithOptions[int](); s.cfg != nil {
+		t.Error("Expected: no settings for the defaults")
+	}
+
+	s := NewWithOptions[int](WithNodeSize(16))
+	c := s.Clone()
+	c.SetSpareNodes(3)
+	if s.opts().spareCap() != defaultSpareNodes || c.opts().spareCap() != 3 || c.opts().nodeCap() != 16 {
+		t.Errorf("Expected: %d and 3 spare nodes; Got: %d and %d", defaultSpareNodes, s.opts().spareCap(), c.opts().spareCap())
+	}
+}
+
+func TestNodeSizeOptionsShouldKeepInvariants(t *testing.T) {
+	tests := []struct {
+		first, size int
+	}{
+		{first: 1, size: 1},
+		{first: 1, size: 3},
+		{first: 3, size: 64},
+		{first: 64, size: 64},
+		{first: 0, size: 4},
+		{first: 5, size: 1000},
+		{first: 100, size: 0},
+	}
+	for _, test := range tests {
+		var opts []Option
+		if test.first > 0 {
+			opts = append(opts, WithFirstNodeSize(test.first))
+		}
+		if test.size > 0 {
+			opts = append(opts, WithNodeSize(test.size))
+		}
+		s := NewWithOptions[int](opts...)
+		size := s.opts().nodeCap()
+		s.Push(0)
+		if want := min(test.first, size); test.first > 0 && cap(s.tail.v) != want {
+			t.Errorf("Expected: first node of %d; Got: %d", want, cap(s.tail.v))
+		}
+		for i := 1; i < pushCount; i++ {
+			s.Push(i)
+		}
+		assertInvariants(t, s, func(i int) int { return i })
+		if len(s.nodes) < pushCount/size {
+			t.Errorf("Expected: at least %d nodes; Got: %d", pushCount/size, len(s.nodes))
+		}
+		vs := make([]int, pushCount/2)
+		s.PopN(vs, len(vs))
+		slices.Reverse(vs)
+		s.PushAll(vs...)
+		assertInvariants(t, s, func(i int) int { return i })
+		for i := 0; i < 10; i++ {
+			s.popBottom()
+		}
+		assertInvariants(t, s, func(i int) int { return 10 + i })
+		for s.Len() > 0 {
+			s.Pop()
+		}
+		assertInvariants(t, s, nil)
+	}
+}
+
+func TestGrowthPoliciesShouldKeepInvariants(t *testing.T) {
+	policies := []GrowthPolicy{
+		GeometricGrowth{},
+		GeometricGrowth{First: 1, Max: 3000},
+		FixedGrowth{Size: 7},
+		&AdaptiveGrowth{Min: 2, Max: 1024},
+	}
+	for _, p := range policies {
+		for k := 0; k < refillCount; k++ {
+			s := NewWithOptions[int](WithGrowthPolicy(p))
+			for i := 0; i < pushCount; i++ {
+				s.Push(i)
+			}
+			assertInvariants(t, s, func(i int) int { return i })
+			vs := make([]int, pushCount/2)
+			s.PopN(vs, len(vs))
+			slices.Reverse(vs)
+			s.PushAll(vs...)
+			assertInvariants(t, s, func(i int) int { return i })
+			for i := 0; i < pushCount/3; i++ {
+				s.popBottom()
+			}
+			assertInvariants(t, s, func(i int) int { return pushCount/3 + i })
+			for s.Len() > 0 {
+				s.popBottom()
+			}
+			assertInvariants(t, s, nil)
+			s.PushAll(1, 2, 3)
+			assertInvariants(t, s, func(i int) int { return i + 1 })
+		}
+	}
+}
+
+func TestGeometricGrowthShouldDoubleNodeSizes(t *testing.T) {
+	s := NewWithOptions[int](WithGrowthPolicy(GeometricGrowth{First: 4, Max: 64}))
+	for i := 0; i < 4+8+16+32+64+64; i++ {
+		s.Push(i)
+	}
+	want := []int{4, 8, 16, 32, 64, 64}
+	if len(s.nodes) != len(want) {
+		t.Fatalf("Expected: %d nodes; Got: %d", len(want), len(s.nodes))
+	}
+	for i, n := range s.nodes {
+		if cap(n.v) != want[i] {
+			t.Errorf("Expected: node %d of size %d; Got: %d", i, want[i], cap(n.v))
+		}
+	}
+}
+
+func TestAdaptiveGrowthShouldLearnHighWaterMark(t *testing.T) {
+	p := &AdaptiveGrowth{Max: 4096}
+	s := NewWithOptions[int](WithGrowthPolicy(p))
+	for i := 0; i < 1000; i++ {
+		s.Push(i)
+	}
+
+	// New stacks start with a first node large enough for the recent mark.
+	s = NewWithOptions[int](WithGrowthPolicy(p))
+	s.Push(0)
+	if c := cap(s.tail.v); c < 512 {
+		t.Errorf("Expected: first node of at least 512; Got: %d", c)
+	}
+
+	// The mark decays as stacks stay small.
+	for i := 0; i < 50; i++ {
+		s = NewWithOptions[int](WithGrowthPolicy(p))
+		s.Push(0)
+	}
+	if c := cap(s.tail.v); c != firstSliceSize {
+		t.Errorf("Expected: first node of %d; Got: %d", firstSliceSize, c)
+	}
+}
+
+func TestAdaptiveGrowthShouldFitRepeatedWorkloadInOneNode(t *testing.T) {
+	p := &AdaptiveGrowth{Max: 4096}
+	s := NewWithOptions[int](WithGrowthPolicy(p))
+	for k := 0; k < 20; k++ {
+		for i := 0; i < 1000; i++ {
+			s.Push(i)
+		}
+		if k > 0 && len(s.nodes) != 1 {
+			t.Errorf("Round %d; Expected: 1 node; Got: %d nodes of first size %d", k, len(s.nodes), cap(s.nodes[0].v))
+		}
+		s.Init()
+	}
+
+	// New stacks learn the mark from the stacks that grew.
+	p = &AdaptiveGrowth{Max: 4096}
+	for k := 0; k < 2; k++ {
+		s = NewWithOptions[int](WithGrowthPolicy(p))
+		for i := 0; i < 1000; i++ {
+			s.Push(i)
+		}
+	}
+	if len(s.nodes) != 1 {
+		t.Errorf("Expected: 1 node; Got: %d nodes of first size %d", len(s.nodes), cap(s.nodes[0].v))
+	}
+}
+
+func TestReserveShouldPreventAllocations(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "Default"},
+		{name: "NodeSize", opts: []Option{WithNodeSize(100)}},
+		{name: "Geometric", opts: []Option{WithGrowthPolicy(GeometricGrowth{First: 2, Max: 1024})}},
+		{name: "Pool", opts: []Option{WithPool(NewPool[int]())}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewWithOptions[int](test.opts...)
+			s.Push(0)
+			// AllocsPerRun runs the function twice.
+			s.Reserve(2 * pushCount)
+			if c := s.Cap(); c < 2*pushCount+1 {
+				t.Errorf("Expected: capacity of at least %d; Got: %d", 2*pushCount+1, c)
+			}
+			c := s.Cap()
+			allocs := testing.AllocsPerRun(1, func() {
+				for i := 0; i < pushCount; i++ {
+					s.Push(s.Len())
+				}
+			})
+			if allocs != 0 {
+				t.Errorf("Expected: no allocations; Got: %v", allocs)
+			}
+			if s.Cap() != c {
+				t.Errorf("Expected: capacity of %d; Got: %d", c, s.Cap())
+			}
+			assertInvariants(t, s, func(i int) int { return i })
+
+			// Reserving room that's already available doesn't allocate.
+			s.PopN(make([]int, 2*pushCount), 2*pushCount)
+			if allocs := testing.AllocsPerRun(1, func() { s.Reserve(1) }); allocs != 0 {
+				t.Errorf("Expected: no allocations; Got: %v", allocs)
+			}
+		})
+	}
+}
+
+func TestShrinkShouldKeepInvariants(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []Option
+		first int
+	}{
+		{name: "Default"},
+		{name: "Geometric", opts: []Option{WithGrowthPolicy(GeometricGrowth{First: 2, Max: 1024})}},
+		{name: "LargeFirstNode", opts: []Option{WithFirstNodeSize(1000)}},
+		{name: "FirstNodeLargerThanNodeSize", first: 1000},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewWithOptions[int](test.opts...)
+			if test.first > 0 {
+				// Bypass the NewWithOptions limit to check Shrink doesn't
+				// rely on it.
+				s.cfg = &settings[int]{options: options{firstNodeSize: test.first}}
+			}
+			for i := 0; i < pushCount+10; i++ {
+				s.Push(i)
+			}
+			snap := s.Snapshot()
+			for i := 0; i < 5; i++ {
+				s.popBottom()
+			}
+			s.Reserve(pushCount)
+			s.Shrink()
+			assertInvariants(t, s, func(i int) int { return 5 + i })
+			if len(s.spare) != 0 {
+				t.Errorf("Expected: no spare nodes; Got: %d", len(s.spare))
+			}
+			if c, l := cap(s.tail.v), len(s.tail.v); c > 2*l {
+				t.Errorf("Expected: last node of at most %d; Got: %d", 2*l, c)
+			}
+			if f := s.nodes[0]; cap(f.v) != len(f.v) {
+				t.Errorf("Expected: compacted first node; Got: %d of %d", len(f.v), cap(f.v))
+			}
+
+			// The stack grows back from the shrunk nodes.
+			for i := 0; i < pushCount; i++ {
+				s.Push(pushCount + 10 + i)
+			}
+			assertInvariants(t, s, func(i int) int { return 5 + i })
+			for i := 0; i < pushCount; i++ {
+				if v, ok := snap.Get(i); !ok || v != i {
+					t.Fatalf("Expected: %d; Got: %d", i, v)
+				}
+			}
+
+			for s.Len() > 1 {
+				s.Pop()
+			}
+			s.Shrink()
+			assertInvariants(t, s, func(i int) int { return 5 })
+			s.Pop()
+			s.Shrink()
+			if s.tail != nil || len(s.nodes) != 0 {
+				t.Errorf("Expected: no nodes; Got: %d", len(s.nodes))
+			}
+			assertInvariants(t, s, nil)
+		})
+	}
+}
+
+func TestResetShouldReuseNodes(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "Default"},
+		{name: "Geometric", opts: []Option{WithGrowthPolicy(GeometricGrowth{First: 2, Max: 1024})}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewWithOptions[int](test.opts...)
+			for i := 0; i < pushCount; i++ {
+				s.Push(i)
+			}
+			c := s.Cap()
+			s.Reset()
+			assertInvariants(t, s, nil)
+			if s.Len() != 0 || s.Cap() != c {
+				t.Errorf("Expected: 0 values and capacity of %d; Got: %d and %d", c, s.Len(), s.Cap())
+			}
+			allocs := testing.AllocsPerRun(1, func() {
+				for i := 0; i < pushCount; i++ {
+					s.Push(i)
+				}
+				s.Reset()
+			})
+			if allocs != 0 {
+				t.Errorf("Expected: no allocations; Got: %v", allocs)
+			}
+
+			// Nodes shared with snapshots aren't reused.
+			for i := 0; i < pushCount; i++ {
+				s.Push(i)
+			}
+			snap := s.Snapshot()
+			s.Reset()
+			for i := 0; i < pushCount; i++ {
+				s.Push(-i)
+			}
+			assertInvariants(t, s, func(i int) int { return -i })
+			for i := 0; i < pushCount; i++ {
+				if v, ok := snap.Get(i); !ok || v != i {
+					t.Fatalf("Expected: %d; Got: %d", i, v)
+				}
+			}
+		})
+	}
+}
+
+func TestStatsShouldMatchNodes(t *testing.T) {
+	s := NewWithOptions[int](WithGrowthPolicy(GeometricGrowth{First: 2, Max: 64}))
+	for i := 0; i < pushCount; i++ {
+		s.Push(i)
+	}
+	snap := s.Snapshot()
+	for i := 0; i < 3; i++ {
+		s.popBottom()
+	}
+	for i := 0; i < 100; i++ {
+		s.Pop()
+	}
+	s.Reserve(200)
+
+	stats := s.Stats()
+	c := 0
+	for _, n := range s.nodes {
+		c += cap(n.v)
+	}
+	for _, n := range s.spare {
+		c += cap(n.v)
+	}
+	if stats.Nodes != len(s.nodes) || stats.SpareNodes != len(s.spare) {
+		t.Errorf("Expected: %d nodes and %d spare nodes; Got: %+v", len(s.nodes), len(s.spare), stats)
+	}
+	if stats.Len != s.Len() || stats.Cap != c || stats.FirstNodeCap != cap(s.nodes[0].v) {
+		t.Errorf("Expected: %d values, capacity of %d and first node of %d; Got: %+v", s.Len(), c, cap(s.nodes[0].v), stats)
+	}
+	if snap.Len() != pushCount {
+		t.Errorf("Expected: %d; Got: %d", pushCount, snap.Len())
+	}
+}
+
+func TestWaitShouldPassSignalOnWhenGivingUp(t *testing.T) {
+	var s BlockingStack[int]
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error)
+	go func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		errc <- s.wait(ctx, &s.poppers, s.poppers.add())
+	}()
+	waitForWaiters(t, &s, &s.poppers, 1)
+
+	s.mu.Lock()
+	next := s.poppers.add()
+	// Once the context is done the waiter gives up, even if it's signaled
+	// before it gets to lock s.mu again.
+	cancel()
+	s.poppers.signal()
+	s.mu.Unlock()
+
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("Expected: %v; Got: %v", context.Canceled, err)
+	}
+	select {
+	case <-next:
+	default:
+		t.Error("Expected: next waiter to be signaled")
+	}
+	if len(s.poppers.ws) != 0 {
+		t.Errorf("Expected: no waiters; Got: %d", len(s.poppers.ws))
+	}
+}
+
+func TestBoundedStackBlockShouldWakeOneProducerPerPop(t *testing.T) {
+	s := NewBounded[int](1, Block)
+	s.Push(0)
+	errs := make(chan error)
+	for i := 1; i <= 3; i++ {
+		go func() {
+			errs <- s.Push(i)
+		}()
+		// Each producer starts waiting before the next one.
+		waitForWaiters(t, s, &s.pushers, i)
+	}
+
+	for i := 0; i <= 3; i++ {
+		v, err := s.PopWait(context.Background())
+		if err != nil || v != i {
+			t.Fatalf("Expected: %d; Got: %d, %v", i, v, err)
+		}
+		s.mu.Lock()
+		l := len(s.pushers.ws)
+		s.mu.Unlock()
+		if want := max(2-i, 0); l != want {
+			t.Errorf("Expected: %d producers still waiting; Got: %d", want, l)
+		}
+		if i < 3 {
+			if err := <-errs; err != nil {
+				t.Errorf("Expected: no error; Got: %v", err)
+			}
+		}
+	}
+}
+
+func TestBoundedStackBlockShouldKeepProducerPlaceUntilItFits(t *testing.T) {
+	s := NewBounded[int](2, Block)
+	s.PushAll(1, 2)
+	errs := make(chan error)
+	go func() {
+		errs <- s.PushAll(3, 4)
+	}()
+	waitForWaiters(t, s, &s.pushers, 1)
+	go func() {
+		errs <- s.Push(5)
+	}()
+	waitForWaiters(t, s, &s.pushers, 2)
+
+	// There's room for 5 but not for 3 and 4, which were pushed first, so 5
+	// waits behind them.
+	if v, ok := s.TryPop(); !ok || v != 2 {
+		t.Fatalf("Expected: 2; Got: %d", v)
+	}
+	waitForWaiters(t, s, &s.pushers, 2)
+	if s.Len() != 1 {
+		t.Errorf("Expected: 1; Got: %d", s.Len())
+	}
+	var got []int
+	for len(got) < 4 {
+		v, err := s.PopWait(context.Background())
+		if err != nil {
+			t.Fatalf("Expected: no error; Got: %v", err)
+		}
+		got = append(got, v)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("Expected: no error; Got: %v", err)
+		}
+	}
+	// 3 and 4 are pushed as soon as 1 is popped, and 5 once 4 is popped.
+	slices.Sort(got[2:])
+	if want := []int{1, 4, 3, 5}; !slices.Equal(got, want) {
+		t.Errorf("Expected: %v; Got: %v", want, got)
+	}
+}
+
 // Helper methods-----------------------------------------------------------------------------------
 
 // Checks the internal slices and its links.
-func checkLinks(t *testing.T, s *Stack, length, tailSliceSize int) {
+func checkLinks(t *testing.T, s *Stack[int], length, tailSliceSize int) {
 	t.Helper()
 	if s.Len() != length {
 		t.Errorf("Unexpected length; Expected: %d; Got: %d", length, s.Len())
@@ -147,7 +1082,7 @@ func checkLinks(t *testing.T, s *Stack, length, tailSliceSize int) {
 // assertInvariants checks all the invariant conditions in d that we can think of.
 // If val is non-nil it is used to find the expected value for an item at index
 // i measured from the head of the stack.
-func assertInvariants(t *testing.T, s *Stack, val func(i int) interface{}) {
+func assertInvariants(t *testing.T, s *Stack[int], val func(i int) int) {
 	t.Helper()
 	fail := func(what string, got, want interface{}) {
 		t.Errorf("invariant fail: %s; got %v want %v", what, got, want)
@@ -163,9 +1098,75 @@ func assertInvariants(t *testing.T, s *Stack, val func(i int) interface{}) {
 		if s.len != 0 {
 			fail("zero length when zero", s.len, 0)
 		}
+		if len(s.nodes) != 0 {
+			fail("empty nodes when zero", len(s.nodes), 0)
+		}
 		return
 	}
+	// Push and Pop leave the nodes behind the tail length kept in top.
+	s.sync()
+	if len(s.tail.v) != s.top {
+		fail("tail length is top", len(s.tail.v), s.top)
+	}
+	if len(s.nodes) == 0 || s.nodes[len(s.nodes)-1] != s.tail {
+		fail("tail is the last node", len(s.nodes), "non-empty nodes ending with tail")
+	}
+	n := s.tail
+	for i := len(s.nodes) - 1; i >= 0 && !t.Failed(); i-- {
+		if s.nodes[i] != n {
+			fail("nodes match the linked list", i, "node in the linked list")
+		}
+		if i > 0 && len(n.v) == 0 {
+			fail("non-empty node", i, "at least one value")
+		}
+		if i > 0 && i < len(s.nodes)-1 && s.opts().growth == nil && len(n.v) != s.opts().nodeCap() {
+			fail("full node", len(n.v), s.opts().nodeCap())
+		}
+		if i > 0 && i < len(s.nodes)-1 && len(n.v) != cap(n.v) {
+			fail("full node", len(n.v), cap(n.v))
+		}
+		if i > 0 && n.i != s.nodes[i-1].i+cap(s.nodes[i-1].v) {
+			fail("node index follows the previous node", n.i, s.nodes[i-1].i+cap(s.nodes[i-1].v))
+		}
+		if i == 0 && n != s.tail && (len(n.v) != cap(n.v) || len(n.v) == 0) {
+			fail("full first node", len(n.v), cap(n.v))
+		}
+		if i == 0 && n.p != n {
+			fail("first node points to itself", n.p, n)
+		}
+		n = n.p
+	}
+	for _, n := range s.spare {
+		if len(n.v) != 0 || (s.opts().growth == nil && cap(n.v) != s.opts().nodeCap()) || n.p != nil {
+			fail("empty spare node", len(n.v), 0)
+		}
+	}
+	if val != nil {
+		for i := 0; i < s.len; i++ {
+			if v, ok := s.Get(i); !ok || v != val(i) {
+				fail("value at index", v, val(i))
+			}
+		}
+	}
 	if t.Failed() {
 		t.FailNow()
 	}
 }
+
+// waitForWaiters waits until at least n goroutines are waiting in q, which
+// belongs to s.
+func waitForWaiters[T any](t *testing.T, s *BlockingStack[T], q *waitq, n int) {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		s.mu.Lock()
+		l := len(q.ws)
+		s.mu.Unlock()
+		if l >= n {
+			// Give the waiters a moment to block in select.
+			time.Sleep(time.Millisecond)
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Expected: %d waiters", n)
+}